@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-johnnyhe/waveland/internal/client"
+	"github.com/go-johnnyhe/waveland/internal/comments"
+	"github.com/go-johnnyhe/waveland/internal/editorapi"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+// commentsCmd represents the comments command
+var commentsCmd = &cobra.Command{
+	Use:   "comments [file]",
+	Short: "List review comments",
+	Long: `List the review comments kept in .waveland/comments.json, for all files or
+just one. Comments follow their lines as the file changes; a comment whose
+lines were deleted is shown as outdated.
+
+Example:
+  waveland comments
+  waveland comments solution.py --all`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := comments.Load(comments.Path)
+		if err != nil {
+			return err
+		}
+		all, _ := cmd.Flags().GetBool("all")
+		comments.Sort(list)
+		shown := 0
+		for _, c := range list {
+			if len(args) == 1 && c.File != filepath.Base(args[0]) || c.Resolved && !all {
+				continue
+			}
+			fmt.Println(client.FormatComment(c))
+			shown++
+		}
+		if shown == 0 {
+			fmt.Println("No comments")
+		}
+		return nil
+	},
+}
+
+// commentCmd represents the comment command
+var commentCmd = &cobra.Command{
+	Use:   "comment <file>:<line>[-<end>] <text>",
+	Short: "Comment on lines of a file in the running session",
+	Long: `Add a comment on a line, or a range of lines, of a file in the session running
+in this directory. Everyone sees it, and editor plugins can show it inline.
+
+Example:
+  waveland comment solution.py:12 "this loop is quadratic"
+  waveland comment solution.py:3-8 "extract a helper?"
+  waveland comment --resolve 1a2b3c4d`,
+	Args:         cobra.ArbitraryArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		api, err := editorapi.Dial(editorapi.SocketPath)
+		if err != nil {
+			return err
+		}
+		defer api.Close()
+
+		resolve, _ := cmd.Flags().GetString("resolve")
+		remove, _ := cmd.Flags().GetString("delete")
+		var comment protocol.Comment
+		switch {
+		case resolve != "" || remove != "":
+			req := map[string]any{"id": resolve}
+			if remove != "" {
+				req = map[string]any{"id": remove, "delete": true}
+			}
+			err = api.Call("comment.resolve", req, &comment)
+		case len(args) >= 2:
+			file, line, end, perr := parseAnchor(args[0])
+			if perr != nil {
+				return perr
+			}
+			req := protocol.Comment{File: file, Line: line, EndLine: end, Text: strings.Join(args[1:], " ")}
+			err = api.Call("comment.add", req, &comment)
+		default:
+			return fmt.Errorf("expected <file>:<line> <text>, or --resolve <id>")
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(client.FormatComment(comment))
+		return nil
+	},
+}
+
+// parseAnchor splits "file:12" or "file:12-15" into a file and line range.
+func parseAnchor(arg string) (string, int, int, error) {
+	i := strings.LastIndex(arg, ":")
+	if i < 0 {
+		return "", 0, 0, fmt.Errorf("missing line in %q, expected <file>:<line>", arg)
+	}
+	lines := strings.SplitN(arg[i+1:], "-", 2)
+	line, err := strconv.Atoi(lines[0])
+	if err != nil || line < 1 {
+		return "", 0, 0, fmt.Errorf("invalid line in %q", arg)
+	}
+	end := line
+	if len(lines) == 2 {
+		if end, err = strconv.Atoi(lines[1]); err != nil || end < line {
+			return "", 0, 0, fmt.Errorf("invalid line range in %q", arg)
+		}
+	}
+	return filepath.Base(arg[:i]), line, end, nil
+}
+
+func init() {
+	rootCmd.AddCommand(commentsCmd)
+	rootCmd.AddCommand(commentCmd)
+	commentsCmd.Flags().Bool("all", false, "include resolved comments")
+	commentCmd.Flags().String("resolve", "", "resolve the comment with this id")
+	commentCmd.Flags().String("delete", "", "delete the comment with this id")
+}
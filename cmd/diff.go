@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-johnnyhe/waveland/internal/diff"
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff <file>@<n> [<file>@<m>]",
+	Short: "Show changes between a recorded version and the current file",
+	Long: `Show a unified diff between a recorded version of a file and its current
+contents on disk, or between two recorded versions.
+
+Example:
+  waveland diff solution.py@3                 # version 3 vs. the file on disk
+  waveland diff solution.py@3 solution.py@5   # version 3 vs. version 5`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := history.Open(history.DefaultDir)
+		if err != nil {
+			return err
+		}
+
+		name, n, err := parseFileVersion(args[0])
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("missing version, expected <file>@<n>")
+		}
+		_, old, err := store.Lookup(name, n)
+		if err != nil {
+			return err
+		}
+		oldLabel := fmt.Sprintf("%s@%d", name, n)
+
+		var current []byte
+		newLabel := name
+		if len(args) == 2 {
+			otherName, m, err := parseFileVersion(args[1])
+			if err != nil {
+				return err
+			}
+			if m == 0 {
+				return fmt.Errorf("missing version, expected <file>@<m>")
+			}
+			if _, current, err = store.Lookup(otherName, m); err != nil {
+				return err
+			}
+			newLabel = fmt.Sprintf("%s@%d", otherName, m)
+		} else if current, err = os.ReadFile(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		fmt.Print(diff.Unified(oldLabel, newLabel, old, current))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
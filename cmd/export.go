@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/go-johnnyhe/waveland/internal/gitexport"
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export --git <dir>",
+	Short: "Export the session history as a git repository",
+	Long: `Turn the changes synced in this directory into a git repository, so a
+session can be archived, reviewed and bisected.
+
+Changes are grouped into one commit per burst of editing: a pause longer than
+--quiet starts a new commit. Each commit is authored by whoever made most of
+its changes, with everyone else credited in Co-authored-by trailers.
+
+Example:
+  waveland export --git ../interview-archive
+  waveland export --git ../interview-archive --quiet 30s`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("git")
+		if dir == "" {
+			return fmt.Errorf("missing --git <dir>")
+		}
+		quiet, _ := cmd.Flags().GetDuration("quiet")
+
+		store, err := history.Open(history.DefaultDir)
+		if err != nil {
+			return err
+		}
+		commits, err := gitexport.Export(store, dir, gitexport.Options{Quiet: quiet})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d commits to %s\n", commits, dir)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().String("git", "", "directory to create the git repository in")
+	exportCmd.Flags().Duration("quiet", gitexport.DefaultQuiet, "pause in editing that starts a new commit")
+}
@@ -8,11 +8,14 @@ import (
     "fmt"
     "os"
     "os/signal"
+    "slices"
     "strings"
     "syscall"
     "github.com/gorilla/websocket"
     "github.com/spf13/cobra"
 	"github.com/go-johnnyhe/waveland/internal/client"
+	"github.com/go-johnnyhe/waveland/internal/console"
+	"github.com/go-johnnyhe/waveland/internal/sharedterm"
 )
 
 // joinCmd represents the join command
@@ -27,6 +30,29 @@ This will:
 
 Example:
   waveland join https://abc123.trycloudflare.com
+  waveland join https://abc123.trycloudflare.com --follow alice --open-cmd "code -g {file}:{line}"
+  waveland join https://abc123.trycloudflare.com --terminal   # in a second window, after joining
+  waveland join https://abc123.trycloudflare.com --confirm
+  waveland join https://abc123.trycloudflare.com --dir ~/pairing
+  waveland join https://abc123.trycloudflare.com --ephemeral --edit
+
+Files are synced into the current directory unless --dir names another one.
+--ephemeral syncs into a fresh temporary directory instead and asks on exit
+whether to keep it, archive it to a .tar.gz next to where you ran join, or
+delete it. --edit opens the workspace in $VISUAL, or $EDITOR if that isn't
+set, e.g. EDITOR="code"; terminal editors need a terminal of their own, so
+for those the command to run in another one is printed instead.
+
+Before live sync starts, the files you already have are compared with the
+host's. Files only the host has are downloaded; for the rest --prefer decides:
+host overwrites yours and keeps your other files to yourself, local sends
+yours, newest goes by modification time, and ask (the default) asks about
+each file. A summary of what was downloaded, uploaded or kept is printed.
+This happens once, when you join.
+
+With --confirm, new files and the first change to each of your existing files
+wait for approval: /staged lists them (or shows a diff), /apply and /discard
+settle them, and /trust <name> lets everything from that peer through.
 
 The session URL comes from whoever ran 'waveland start'.`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -36,6 +62,11 @@ The session URL comes from whoever ran 'waveland start'.`,
 			cmd.Usage()
 			return
 		}
+		prefer, _ := cmd.Flags().GetString("prefer")
+		if !slices.Contains(client.Prefers, prefer) {
+			fmt.Printf("Error: --prefer must be one of %s\n", strings.Join(client.Prefers, ", "))
+			return
+		}
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer stop()
 		sessionUrl := args[0]
@@ -51,15 +82,93 @@ The session URL comes from whoever ran 'waveland start'.`,
 		}
 		defer conn.Close()
 
-		c := client.NewClient(conn)
+		// the token ties a --terminal window to our session in another one
+		tokenFile := sharedterm.TokenPath(wsURL)
+		if terminal, _ := cmd.Flags().GetBool("terminal"); terminal {
+			token, _ := os.ReadFile(tokenFile)
+			if err := sharedterm.View(ctx, conn, participantName(cmd), strings.TrimSpace(string(token))); err != nil {
+				fmt.Println(err)
+			}
+			return
+		}
+
+		workspace, err := enterWorkspace(cmd)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		con := console.New()
+		opts := clientOptions(cmd)
+		opts.Confirm, _ = cmd.Flags().GetBool("confirm")
+		opts.Prefer = prefer
+		opts.Approve = con.Confirm
+		opts.TerminalTokenFile = tokenFile
+		defer os.Remove(tokenFile)
+		c := client.NewClient(conn, opts)
 		c.Start(ctx)
 
+		addClientCommands(con, c)
+		if opts.Confirm {
+			addStageCommands(con, c)
+		}
+		// the console outlives the session to ask about an ephemeral workspace
+		go con.Run(context.Background())
+		fmt.Println("Type to chat, or /help for session commands")
+
 		<-ctx.Done()
 		fmt.Println("")
+		saveChatLog(cmd, c)
+		if workspace.ephemeral {
+			stop()
+			workspace.close(con)
+		}
 		fmt.Println("Goodbye!")
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(joinCmd)
+	addSessionFlags(joinCmd)
+	joinCmd.Flags().Bool("terminal", false, "watch the host's shared shell instead of syncing files")
+	joinCmd.Flags().Bool("confirm", false, "approve new files and first overwrites before they are written")
+	joinCmd.Flags().String("prefer", client.PreferAsk, "when your files differ from the host's on joining: host, local, newest or ask")
+	joinCmd.Flags().String("dir", "", "directory to sync into (default: the current directory)")
+	joinCmd.Flags().Bool("ephemeral", false, "sync into a temporary directory and decide what to keep on exit")
+	joinCmd.Flags().Bool("edit", false, "open the workspace in $VISUAL or $EDITOR")
+}
+
+// addStageCommands settles the changes --confirm holds back.
+func addStageCommands(con *console.Console, c *client.Client) {
+	con.Handle("staged", "[file]", "list changes waiting for approval, or show one as a diff", func(args []string) {
+		if len(args) > 0 {
+			d, err := c.StagedDiff(args[0])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Print(d)
+			return
+		}
+		staged := c.Staged()
+		if len(staged) == 0 {
+			fmt.Println("Nothing staged")
+		}
+		for _, s := range staged {
+			fmt.Printf("  %s\n", s.Summary())
+		}
+	})
+	con.Handle("apply", "[file...]", "write staged changes, all of them by default", func(args []string) {
+		c.Apply(args...)
+	})
+	con.Handle("discard", "[file...]", "drop staged changes, all of them by default", func(args []string) {
+		c.Discard(args...)
+	})
+	con.Handle("trust", "<name>", "apply everything from a peer for the rest of the session", func(args []string) {
+		if len(args) != 1 {
+			fmt.Println("Usage: /trust <name>")
+			return
+		}
+		c.Trust(args[0])
+	})
 }
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/judge"
+	"github.com/go-johnnyhe/waveland/internal/problem"
+	"github.com/spf13/cobra"
+)
+
+// judgeCmd represents the judge command
+var judgeCmd = &cobra.Command{
+	Use:   "judge [solution]",
+	Short: "Run the solution against the problem's tests and report verdicts",
+	Long: `Build and run the solution against the examples and hidden tests of the
+problem loaded with 'waveland start --problem', each with the problem's time
+and memory limits (enforced with rlimits on Linux).
+
+Tests run in a scratch directory, so hidden tests never end up in the shared
+directory. Every result is logged to .waveland/judge.jsonl.
+
+Example:
+  waveland judge                          # judge the session's solution
+  waveland judge --examples               # only the visible examples
+  waveland judge sol.py --problem two-sum --lang python`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		examples, _ := cmd.Flags().GetBool("examples")
+		verbose, _ := cmd.Flags().GetBool("verbose")
+		session := problem.Session{}
+		if ref, _ := cmd.Flags().GetString("problem"); ref != "" {
+			session.Source = ref
+		} else {
+			s, err := problem.LoadSession()
+			if err != nil {
+				return err
+			}
+			session = s
+		}
+		if lang, _ := cmd.Flags().GetString("lang"); lang != "" {
+			session.Lang = lang
+		}
+		if len(args) == 1 {
+			session.Solution = args[0]
+		}
+		result, err := judgeSolution(cmd.Context(), session, examples)
+		if err != nil {
+			return err
+		}
+		printJudgeResult(result, verbose)
+		if result.Verdict != judge.Accepted {
+			os.Exit(1)
+		}
+		return nil
+	},
+}
+
+// judgeSolution runs a judge and logs the result.
+func judgeSolution(ctx context.Context, session problem.Session, examplesOnly bool) (*judge.Result, error) {
+	p, err := problem.Load(session.Source)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+	lang, err := p.Language(session.Lang)
+	if err != nil {
+		return nil, err
+	}
+	solution := session.Solution
+	if solution == "" {
+		solution = p.SolutionFile(lang)
+	}
+
+	fmt.Printf("Judging %s (%s) on %s...\n", solution, lang, p.Title)
+	result, err := judge.Run(ctx, p, judge.Options{Lang: lang, Solution: solution, ExamplesOnly: examplesOnly})
+	if err != nil {
+		return nil, err
+	}
+	if err := judge.Append(result); err != nil {
+		fmt.Println("Failed to log the result: ", err)
+	}
+	return result, nil
+}
+
+func printJudgeResult(r *judge.Result, verbose bool) {
+	if r.Verdict == judge.CompilationError {
+		fmt.Println(r.CompileOutput)
+		fmt.Println(r.Verdict)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, t := range r.Tests {
+		name := t.Name
+		if t.Hidden {
+			name += " (hidden)"
+		}
+		memory := "-"
+		if t.Memory > 0 {
+			memory = fmt.Sprintf("%.1f MB", float64(t.Memory)/(1<<20))
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", name, t.Verdict, t.Time.Round(time.Millisecond), memory)
+	}
+	w.Flush()
+	if verbose {
+		for _, t := range r.Tests {
+			if t.Verdict == judge.Accepted {
+				continue
+			}
+			fmt.Printf("\n%s: %s\n", t.Name, t.Verdict)
+			if t.Output != "" {
+				fmt.Printf("output:\n%s\n", t.Output)
+			}
+			if t.Stderr != "" {
+				fmt.Printf("stderr:\n%s\n", t.Stderr)
+			}
+		}
+	}
+	fmt.Printf("%s (%d/%d passed)\n", r.Verdict, r.Passed, r.Total)
+}
+
+func init() {
+	rootCmd.AddCommand(judgeCmd)
+	judgeCmd.Flags().String("problem", "", "problem package to judge against (default: the one the session started with)")
+	judgeCmd.Flags().String("lang", "", "language of the solution")
+	judgeCmd.Flags().Bool("examples", false, "only run the visible examples")
+	judgeCmd.Flags().BoolP("verbose", "v", false, "show the output of failed tests")
+}
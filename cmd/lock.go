@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/editorapi"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+// lockCmd represents the lock command
+var lockCmd = &cobra.Command{
+	Use:   "lock <file>",
+	Short: "Claim a file so only you can change it",
+	Long: `Claim a file in the session running in this directory. Until you unlock it
+or leave, changes to it from anyone else are rejected and they are told so.
+
+Example:
+  waveland lock solution.py
+  waveland unlock solution.py`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return changeLock("lock.acquire", args[0])
+	},
+}
+
+// unlockCmd represents the unlock command
+var unlockCmd = &cobra.Command{
+	Use:          "unlock <file>",
+	Short:        "Release a file you locked",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return changeLock("lock.release", args[0])
+	},
+}
+
+// changeLock asks the session for a lock change and waits for the server's
+// answer.
+func changeLock(method, file string) error {
+	api, err := editorapi.Dial(editorapi.SocketPath)
+	if err != nil {
+		return err
+	}
+	defer api.Close()
+
+	var info struct {
+		Name string `json:"name"`
+	}
+	if err := api.Call("session.info", nil, &info); err != nil {
+		return err
+	}
+	file = filepath.Base(file)
+	if err := api.Call(method, protocol.Lock{File: file}, nil); err != nil {
+		return err
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case note, ok := <-api.Notifications():
+			if !ok {
+				return fmt.Errorf("session ended")
+			}
+			switch note.Method {
+			case "lock.update":
+				var lock protocol.Lock
+				if json.Unmarshal(note.Params, &lock) != nil || lock.File != file {
+					continue
+				}
+				if method == "lock.acquire" && lock.Owner == info.Name {
+					fmt.Printf("Locked %s\n", file)
+					return nil
+				}
+				if method == "lock.release" && lock.Owner == "" {
+					fmt.Printf("Unlocked %s\n", file)
+					return nil
+				}
+			case "rejected":
+				var rejected protocol.Rejected
+				if json.Unmarshal(note.Params, &rejected) != nil || rejected.File != file {
+					continue
+				}
+				return fmt.Errorf("%s: %s", file, rejected.Reason)
+			}
+		case <-timeout:
+			return fmt.Errorf("no answer from the session")
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(unlockCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/spf13/cobra"
+)
+
+// logCmd represents the log command
+var logCmd = &cobra.Command{
+	Use:   "log <file>",
+	Short: "List the recorded versions of a shared file",
+	Long: `List every version of a file that was synced during sessions in this directory.
+
+Each change applied locally or received from your partner is kept as a version
+with its timestamp and author. Use the version numbers with 'waveland diff' and
+'waveland restore'.
+
+Example:
+  waveland log solution.py`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := history.Open(history.DefaultDir)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(args[0])
+		versions, err := store.Versions(name)
+		if err != nil {
+			return err
+		}
+		if len(versions) == 0 {
+			fmt.Printf("No history for %s\n", name)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			fmt.Fprintf(w, "%s@%d\t%s\t%s\t%s\t%d bytes\n",
+				name, v.N, v.Time.Format("2006-01-02 15:04:05"), v.Author, v.Hash[:8], v.Size)
+		}
+		return w.Flush()
+	},
+}
+
+// parseFileVersion splits "file@n" into the history name of the file and the
+// version number. The version is 0 when none was given.
+func parseFileVersion(arg string) (string, int, error) {
+	i := strings.LastIndex(arg, "@")
+	if i < 0 {
+		return filepath.Base(arg), 0, nil
+	}
+	n, err := strconv.Atoi(arg[i+1:])
+	if err != nil || n < 1 {
+		return "", 0, fmt.Errorf("invalid version in %q, expected <file>@<n>", arg)
+	}
+	return filepath.Base(arg[:i]), n, nil
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/record"
+	"github.com/spf13/cobra"
+)
+
+// replayCmd represents the replay command
+var replayCmd = &cobra.Command{
+	Use:   "replay <recording>",
+	Short: "Replay a recorded session into a directory",
+	Long: `Rebuild the files of a session recorded with 'waveland start --record' and
+watch them change over time, at the pace they were originally written.
+
+Open the replayed files in your editor (with autoread, see 'waveland vimSetup')
+and control playback from the terminal:
+
+  <enter>      pause / resume
+  + / -        double / halve the speed
+  s <time>     seek, e.g. "s 90s", "s 4:30" or "s 50%"
+  q            quit
+
+Example:
+  waveland replay session.wlrec
+  waveland replay session.wlrec --out /tmp/replay --speed 4`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rec, err := record.Open(args[0])
+		if err != nil {
+			return err
+		}
+		if len(rec.Events) == 0 {
+			fmt.Println("Recording has no changes")
+			return nil
+		}
+
+		outDir, _ := cmd.Flags().GetString("out")
+		if outDir == "" {
+			outDir = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0])) + "-replay"
+		}
+		speed, _ := cmd.Flags().GetFloat64("speed")
+
+		player, err := record.NewPlayer(rec, outDir)
+		if err != nil {
+			return err
+		}
+		if err := player.Seek(0); err != nil {
+			return err
+		}
+		player.SetSpeed(speed)
+
+		total := rec.Duration()
+		ended := make(chan struct{}, 1)
+		player.OnEvent = func(ev record.Event) {
+			fmt.Printf("[%s] %s: %s\n", formatOffset(ev.At), ev.Author, ev.File)
+		}
+		player.OnEnd = func() {
+			fmt.Printf("End of recording (%s). Seek with 's <time>', <enter> to restart or 'q' to quit.\n", formatOffset(total))
+			select {
+			case ended <- struct{}{}:
+			default:
+			}
+		}
+
+		fmt.Printf("Replaying %d changes to %d files (%s, started %s) into %s\n",
+			len(rec.Events), len(rec.Files()), formatOffset(total), rec.Start.Format("2006-01-02 15:04"), outDir)
+		fmt.Println("<enter> pause/resume, +/- speed, s <time> seek, q quit")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- player.Run(ctx)
+		}()
+
+		lines := make(chan string)
+		go func() {
+			defer close(lines)
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				lines <- strings.TrimSpace(scanner.Text())
+			}
+		}()
+
+		stdinClosed := false
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case err := <-errs:
+				return err
+			case <-ended:
+				if stdinClosed {
+					return nil
+				}
+			case line, ok := <-lines:
+				if !ok {
+					lines = nil
+					stdinClosed = true
+					continue
+				}
+				switch {
+				case line == "" || line == "p":
+					paused, err := player.TogglePause()
+					if err != nil {
+						return err
+					}
+					if paused {
+						fmt.Printf("Paused at %s\n", formatOffset(player.Position()))
+					} else {
+						fmt.Printf("Playing from %s at %gx\n", formatOffset(player.Position()), player.Speed())
+					}
+				case line == "+":
+					player.SetSpeed(player.Speed() * 2)
+					fmt.Printf("Speed %gx\n", player.Speed())
+				case line == "-":
+					player.SetSpeed(player.Speed() / 2)
+					fmt.Printf("Speed %gx\n", player.Speed())
+				case strings.HasPrefix(line, "s "):
+					at, err := parseOffset(strings.TrimSpace(line[2:]), total)
+					if err != nil {
+						fmt.Println(err)
+						continue
+					}
+					if err := player.Seek(at); err != nil {
+						return err
+					}
+					fmt.Printf("Jumped to %s\n", formatOffset(player.Position()))
+				case line == "q":
+					return nil
+				default:
+					fmt.Println("Unknown command, use <enter>, +, -, s <time> or q")
+				}
+			}
+		}
+	},
+}
+
+func formatOffset(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// parseOffset accepts "90", "90s", "1m30s", "1:30" or "50%" (of total).
+func parseOffset(s string, total time.Duration) (time.Duration, error) {
+	if strings.HasSuffix(s, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid percentage %q", s)
+		}
+		return time.Duration(float64(total) * pct / 100), nil
+	}
+	if min, sec, ok := strings.Cut(s, ":"); ok {
+		m, err1 := strconv.Atoi(min)
+		sc, err2 := strconv.Atoi(sec)
+		if err1 != nil || err2 != nil {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		return time.Duration(m)*time.Minute + time.Duration(sc)*time.Second, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return d, nil
+}
+
+func init() {
+	rootCmd.AddCommand(replayCmd)
+	replayCmd.Flags().String("out", "", "directory to rebuild the files in (default <recording>-replay)")
+	replayCmd.Flags().Float64("speed", 1, "playback speed multiplier")
+}
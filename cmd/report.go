@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/go-johnnyhe/waveland/internal/report"
+	"github.com/spf13/cobra"
+)
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Write a feedback report for the latest session",
+	Long: `Write a report of the latest session started in this directory: when files
+changed and who changed them, the time to the first passing run, judge
+verdicts, hints, the chat and the final code, followed by a rubric.
+
+The rubric is a Go text/template, read from --rubric or .waveland/rubric.md,
+with fields like {{.Candidate}}, {{.Verdict}}, {{.Duration}} and {{len .Hints}}.
+
+The report is written to .waveland/report.md, which is never synced. With
+--share it is written to the shared directory instead, so a running session
+sends it to the candidate.
+
+Example:
+  waveland report                      # .waveland/report.md
+  waveland report -o feedback.html     # HTML, by extension
+  waveland report --share              # REPORT.md, shared with the candidate`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		share, _ := cmd.Flags().GetBool("share")
+		format, _ := cmd.Flags().GetString("format")
+		if output == "" {
+			output = filepath.Join(".waveland", "report.md")
+			if share {
+				output = "REPORT.md"
+			}
+			if format == "html" {
+				output = strings.TrimSuffix(output, ".md") + ".html"
+			}
+		}
+		if format == "" {
+			format = "md"
+			if ext := strings.ToLower(filepath.Ext(output)); ext == ".html" || ext == ".htm" {
+				format = "html"
+			}
+		}
+
+		rubricPath, _ := cmd.Flags().GetString("rubric")
+		rubric, err := report.LoadRubric(rubricPath)
+		if err != nil {
+			return err
+		}
+		store, err := history.Open(history.DefaultDir)
+		if err != nil {
+			return err
+		}
+		r, err := report.Build(store, rubric)
+		if err != nil {
+			return err
+		}
+
+		var out []byte
+		switch format {
+		case "md", "markdown":
+			out, err = r.Markdown()
+		case "html":
+			out, err = r.HTML()
+		default:
+			return fmt.Errorf("unknown format %q, use md or html", format)
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(output, out, 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", output)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringP("output", "o", "", "where to write the report")
+	reportCmd.Flags().String("format", "", "md or html (default: by the output's extension)")
+	reportCmd.Flags().String("rubric", "", "rubric template (default: .waveland/rubric.md or a built-in one)")
+	reportCmd.Flags().Bool("share", false, "write REPORT.md into the shared directory so the candidate gets it")
+}
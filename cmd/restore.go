@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>@<n>",
+	Short: "Restore a file to an older recorded version",
+	Long: `Overwrite a file with one of its recorded versions (see 'waveland log').
+
+When a session is running in this directory the restored file is picked up
+like any other save and broadcast to everyone in the session.
+
+Example:
+  waveland restore solution.py@2`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := history.Open(history.DefaultDir)
+		if err != nil {
+			return err
+		}
+		name, n, err := parseFileVersion(args[0])
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("missing version, expected <file>@<n>")
+		}
+		v, content, err := store.Lookup(name, n)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, content, 0644); err != nil {
+			return fmt.Errorf("failed to restore %s: %v", name, err)
+		}
+		fmt.Printf("Restored %s to version %d (%s by %s)\n", name, v.N, v.Time.Format("15:04:05"), v.Author)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
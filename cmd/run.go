@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/go-johnnyhe/waveland/internal/editorapi"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/spf13/cobra"
+)
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run -- <command> [args...]",
+	Short: "Ask the host to run a command and stream its output",
+	Long: `Ask the host of the session running in this directory to run a command in
+the shared directory, e.g. the tests. The host approves the request (or has it
+on their allowlist), and the output and exit code are streamed to everyone.
+
+Example:
+  waveland run -- go test ./...
+  waveland run -- python3 solution.py`,
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		api, err := editorapi.Dial(editorapi.SocketPath)
+		if err != nil {
+			return err
+		}
+		defer api.Close()
+
+		var started struct {
+			ID string `json:"id"`
+		}
+		if err := api.Call("run.request", protocol.RunRequest{Args: args}, &started); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Waiting for the host...")
+
+		for note := range api.Notifications() {
+			switch note.Method {
+			case "run.output":
+				var out protocol.RunOutput
+				if json.Unmarshal(note.Params, &out) != nil || out.ID != started.ID {
+					continue
+				}
+				if out.Stream == "stderr" {
+					os.Stderr.Write(out.Data)
+				} else {
+					os.Stdout.Write(out.Data)
+				}
+			case "run.exit":
+				var exit protocol.RunExit
+				if json.Unmarshal(note.Params, &exit) != nil || exit.ID != started.ID {
+					continue
+				}
+				if exit.Error != "" {
+					return fmt.Errorf("%s", exit.Error)
+				}
+				api.Close()
+				os.Exit(exit.Code)
+			}
+		}
+		return fmt.Errorf("session ended before the command finished")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+}
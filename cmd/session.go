@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-johnnyhe/waveland/internal/client"
+	"github.com/go-johnnyhe/waveland/internal/console"
+	"github.com/go-johnnyhe/waveland/internal/editorapi"
+	"github.com/spf13/cobra"
+)
+
+// participantName returns --name, falling back to git's user.name and then
+// the login name.
+func participantName(cmd *cobra.Command) string {
+	if name, _ := cmd.Flags().GetString("name"); name != "" {
+		return name
+	}
+	if out, err := exec.Command("git", "config", "user.name").Output(); err == nil {
+		if name := strings.TrimSpace(string(out)); name != "" {
+			return name
+		}
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "anonymous"
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// addSessionFlags registers the flags shared by start and join.
+func addSessionFlags(cmd *cobra.Command) {
+	cmd.Flags().String("name", "", "your name in the session (default: git user.name)")
+	cmd.Flags().String("follow", "", "keep your editor on the file this participant is editing")
+	cmd.Flags().String("open-cmd", "", `command that opens the followed file, e.g. "code -g {file}:{line}"`)
+	cmd.Flags().String("chat-log", "", "write the chat transcript to this file when the session ends")
+}
+
+// clientOptions builds the client options common to start and join.
+func clientOptions(cmd *cobra.Command) client.Options {
+	follow, _ := cmd.Flags().GetString("follow")
+	openCmd, _ := cmd.Flags().GetString("open-cmd")
+	return client.Options{
+		Name:         participantName(cmd),
+		EditorSocket: editorapi.SocketPath,
+		Follow:       follow,
+		OpenCommand:  openCmd,
+	}
+}
+
+// addClientCommands registers the console commands every participant has.
+// Lines that aren't commands are sent as chat.
+func addClientCommands(con *console.Console, c *client.Client) {
+	con.HandleText(func(line string) {
+		if err := c.Say(line); err != nil {
+			fmt.Println("Error sending chat message: ", err)
+		}
+	})
+	con.Handle("follow", "[name]", "follow a participant's edits, or show who you follow", func(args []string) {
+		if len(args) == 0 {
+			if name := c.Following(); name != "" {
+				fmt.Printf("Following %s\n", name)
+			} else {
+				fmt.Println("Not following anyone")
+			}
+			return
+		}
+		c.Follow(args[0])
+	})
+	con.Handle("unfollow", "", "stop following", func([]string) {
+		c.Follow("")
+	})
+	con.Handle("pass", "[name]", "mob mode: hand the driver role on", func(args []string) {
+		to := ""
+		if len(args) > 0 {
+			to = args[0]
+		}
+		if err := c.Handoff(to); err != nil {
+			fmt.Println("Error handing off: ", err)
+		}
+	})
+	con.Handle("lock", "<file>", "claim a file so only you can change it", func(args []string) {
+		if len(args) != 1 {
+			fmt.Println("Usage: /lock <file>")
+			return
+		}
+		if err := c.Lock(args[0]); err != nil {
+			fmt.Println("Error locking: ", err)
+		}
+	})
+	con.Handle("unlock", "<file>", "release a file you locked", func(args []string) {
+		if len(args) != 1 {
+			fmt.Println("Usage: /unlock <file>")
+			return
+		}
+		if err := c.Unlock(args[0]); err != nil {
+			fmt.Println("Error unlocking: ", err)
+		}
+	})
+	con.Handle("locks", "", "list locked files", func([]string) {
+		locks := c.Locks()
+		if len(locks) == 0 {
+			fmt.Println("No files are locked")
+		}
+		for _, lock := range locks {
+			fmt.Printf("  %s  %s\n", lock.File, lock.Owner)
+		}
+	})
+	con.Handle("comment", "<file>:<line>[-<end>] <text>", "comment on lines of a file", func(args []string) {
+		if len(args) < 2 {
+			fmt.Println("Usage: /comment <file>:<line>[-<end>] <text>")
+			return
+		}
+		file, line, end, err := parseAnchor(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		comment, err := c.AddComment(file, line, end, strings.Join(args[1:], " "))
+		if err != nil {
+			fmt.Println("Error commenting: ", err)
+			return
+		}
+		fmt.Printf("💬 %s\n", client.FormatComment(comment))
+	})
+	con.Handle("comments", "[file]", "list open comments", func(args []string) {
+		file := ""
+		if len(args) == 1 {
+			file = filepath.Base(args[0])
+		}
+		shown := 0
+		for _, comment := range c.Comments(file) {
+			if !comment.Resolved {
+				fmt.Printf("  %s\n", client.FormatComment(comment))
+				shown++
+			}
+		}
+		if shown == 0 {
+			fmt.Println("No open comments")
+		}
+	})
+	con.Handle("resolve", "<id>", "resolve a comment", func(args []string) {
+		if len(args) != 1 {
+			fmt.Println("Usage: /resolve <id>")
+			return
+		}
+		if _, err := c.ResolveComment(args[0], false); err != nil {
+			fmt.Println(err)
+		}
+	})
+	con.Handle("run", "<command>", "run a command on the host (needs the host's approval)", func(args []string) {
+		if _, err := c.RequestRun("", args); err != nil {
+			fmt.Println("Error requesting run: ", err)
+		}
+	})
+}
+
+// saveChatLog writes the transcript if --chat-log was given.
+func saveChatLog(cmd *cobra.Command, c *client.Client) {
+	path, _ := cmd.Flags().GetString("chat-log")
+	if path == "" || c == nil {
+		return
+	}
+	if err := c.WriteChatTranscript(path); err != nil {
+		fmt.Println("Failed to write chat transcript: ", err)
+		return
+	}
+	fmt.Printf("Chat transcript saved to %s\n", path)
+}
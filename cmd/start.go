@@ -7,10 +7,21 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 	"github.com/go-johnnyhe/waveland/internal/client"
+	"github.com/go-johnnyhe/waveland/internal/config"
+	"github.com/go-johnnyhe/waveland/internal/console"
+	"github.com/go-johnnyhe/waveland/internal/gitexport"
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/go-johnnyhe/waveland/internal/journal"
+	"github.com/go-johnnyhe/waveland/internal/problem"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/go-johnnyhe/waveland/internal/record"
+	"github.com/go-johnnyhe/waveland/internal/sharedterm"
 	"github.com/go-johnnyhe/waveland/internal/tunnel"
 	"github.com/gorilla/websocket"
 	"github.com/spf13/cobra"
@@ -19,7 +30,7 @@ import (
 
 // startCmd represents the start command
 var startCmd = &cobra.Command{
-	Use:   "start <file>",
+	Use:   "start [file]",
 	Short: "Start a collaborative coding session and share files",
 	Long: `Start a new collaborative coding session with instant file sharing.
 
@@ -32,19 +43,87 @@ This command will:
 Example:
   waveland start main.py              # Share a single file
   waveland start .                    # Share current directory
+  waveland start . --record s.wlrec   # Also record the session for 'waveland replay'
+  waveland start . --export-git ../s  # Save the session as a git repository on exit
+  waveland start . --shell            # Also share a terminal (Linux)
+  waveland start . --on-change "go test ./..."   # Run the tests after every change
+  waveland start --problem two-sum --lang python # Set up an interview problem
+  waveland start --problem two-sum --interview   # ...as the interviewer, see below
+  waveland start . --duration 45m --freeze       # Time-box it, read-only at the end
+  waveland start . --mob --rotate 10m            # Mob programming, see below
+  waveland start . --review                      # Peers suggest, you decide
+
+A problem is a directory or .zip/.tar.gz with a problem.json manifest, given
+by path or by name from ~/.waveland/problems:
+
+  {
+    "title": "Two Sum",
+    "statement": "statement.md",
+    "starters": {"python": "starters/solution.py", "go": "starters/main.go"},
+    "examples": [{"input": "2 7 11 15\n9\n", "output": "0 1\n"}],
+    "tests": "tests"
+  }
+
+The statement and examples are shared as PROBLEM.md along with the starter
+code. Hidden tests (NAME.in / NAME.out files in "tests") stay with you.
+
+Peers can ask you to run commands here with 'waveland run -- <cmd>'. You are
+asked each time, unless the command matches an allow pattern in
+.waveland/config.json:
+
+  {"run": {"allow": ["go test ./...", "make test"]}}
+
+Patterns are compared argument by argument; a * stands for one argument that
+isn't a flag, so "go test *" allows "go test ./pkg" but not "go test -exec ...".
+
+With --interview you are the interviewer and everyone who joins is a
+candidate. NOTES.md, the "interview.private" patterns in .waveland/config.json
+and --private files stay with you: they are never sent, the server drops them,
+and candidates refuse them. Patterns match file names, e.g. "notes*.md".
+
+/hint releases the problem's next hint (or /hint <text> one of your own):
+it is appended to HINTS.md and shown to everyone. /hints lists them.
+
+--duration starts a timer when the first peer joins; everyone gets notices
+as time runs out. In the session, /phase marks phases (clarify, code, test,
+discuss) and /freeze makes files read-only for everyone but you, which
+--freeze does automatically when time is up.
+
+In mob mode only the driver's changes are shared; everyone else navigates.
+The role goes around in the order people joined, every --rotate or when the
+driver types /pass. You can also /driver <name>, /rotate or /mob off.
+
+With --review, changes from peers arrive as proposals with a diff instead of
+being applied. /accept <id> applies one (or /accept <id> 1 3 some of its
+hunks) and shares the result, /reject <id> turns it down.
 
 The generated URL can be shared with anyone - they can join using:
   waveland join <your-session-url>
 
 Perfect for mock interviews, pair programming, and collaborative debugging.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) != 1 {
-			fmt.Println("Error: this takes exactly one file")
+		problemRef, _ := cmd.Flags().GetString("problem")
+		if problemRef != "" && len(args) != 0 || problemRef == "" && len(args) != 1 {
+			fmt.Println("Error: this takes exactly one file, or --problem")
 			cmd.Usage()
 			return
 		}
 
-		fileName := args[0]
+		var fileName string
+		var shared []string
+		if problemRef != "" {
+			lang, _ := cmd.Flags().GetString("lang")
+			files, err := loadProblem(problemRef, lang)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fileName = files[len(files)-1]
+			shared = files
+		} else {
+			fileName = args[0]
+			shared = []string{fileName}
+		}
 
 		if _, err := os.Stat(fileName); os.IsNotExist(err) {
 			if f, err := os.Create(fileName); err != nil {
@@ -61,9 +140,56 @@ Perfect for mock interviews, pair programming, and collaborative debugging.`,
 
 		// fmt.Printf("Starting the mock session with %s\n", fileName)
 
+		var recorder *record.Writer
+		if recordPath, _ := cmd.Flags().GetString("record"); recordPath != "" {
+			w, err := record.Create(recordPath)
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			defer w.Close()
+			recorder = w
+			fmt.Printf("Recording session to %s\n", recordPath)
+		}
+
+		sessionStart := time.Now()
+
+		cfg, err := config.Load()
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		private, _ := cmd.Flags().GetStringSlice("private")
+		if interview, _ := cmd.Flags().GetBool("interview"); interview {
+			private = append(append([]string{notesFile}, cfg.Interview.Private...), private...)
+			if err := createNotes(); err != nil {
+				fmt.Println(err)
+				return
+			}
+			server.Interview = true
+			fmt.Printf("Interview mode: private to you: %s\n", strings.Join(private, ", "))
+		}
+		server.Private = private
+
+		journal.Add(journal.KindStart, participantName(cmd), fileName)
+		review, _ := cmd.Flags().GetBool("review")
+		server.Review = review
+		if mob, _ := cmd.Flags().GetBool("mob"); mob {
+			rotate, _ := cmd.Flags().GetDuration("rotate")
+			server.StartMob(rotate)
+		}
+		if duration, _ := cmd.Flags().GetDuration("duration"); duration > 0 {
+			freeze, _ := cmd.Flags().GetBool("freeze")
+			server.SetTimer(duration, freeze)
+		}
+
 		// Create a context to link with a command line process so that when you stop, we know where to exit
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer stop()
+		hostToken := randomToken()
+		server.HostToken = hostToken
+
 		// start server in go routine
 		http.HandleFunc("/ws", server.StartServer)
 		srv := &http.Server{Addr: ":8080"}
@@ -103,6 +229,9 @@ Perfect for mock interviews, pair programming, and collaborative debugging.`,
 			fmt.Printf("\n  waveland join %s\n", tunnelURL)
 		}
 
+		con := console.New()
+		hostClient := make(chan *client.Client, 1)
+
 		// let the starter user connect as a client too
 		go func(ctx context.Context) {
 			time.Sleep(500 * time.Millisecond)
@@ -113,16 +242,70 @@ Perfect for mock interviews, pair programming, and collaborative debugging.`,
 			}
 			defer conn.Close()
 
-			c := client.NewClient(conn)
-			c.SendFile(fileName)
+			opts := clientOptions(cmd)
+			opts.HostToken = hostToken
+			opts.Recorder = recorder
+			opts.RunAllow = cfg.Run.Allow
+			opts.Private = private
+			opts.OnChange, _ = cmd.Flags().GetString("on-change")
+			opts.Approve = con.Confirm
+			c := client.NewClient(conn, opts)
 			c.Start(ctx)
+			for _, f := range shared {
+				c.SendFile(f)
+			}
+			addClientCommands(con, c)
+			addHintCommands(con, c)
+			if review {
+				addReviewCommands(con, c)
+			}
+			hostClient <- c
 			<-ctx.Done()
 		}(ctx)
 
+		con.Handle("who", "", "list everyone in the session", func([]string) {
+			printParticipants(server.Participants())
+		})
+		addTimerCommands(con)
+		addMobCommands(con)
+		if problemRef != "" {
+			con.Handle("judge", "[examples]", "run the solution against the problem's tests", func(args []string) {
+				session, err := problem.LoadSession()
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				examplesOnly := len(args) == 1 && args[0] == "examples"
+				go func() {
+					result, err := judgeSolution(ctx, session, examplesOnly)
+					if err != nil {
+						fmt.Println("Judge failed: ", err)
+						return
+					}
+					printJudgeResult(result, false)
+				}()
+			})
+		}
+		if shareShell, _ := cmd.Flags().GetBool("shell"); shareShell {
+			if err := startSharedShell(ctx, con); err != nil {
+				fmt.Println(err)
+			}
+		}
+		go con.Run(ctx)
+		fmt.Println("\nType to chat, or /help for session commands")
+
 		<-ctx.Done()
 		srv.Shutdown(context.Background())
 		time.Sleep(100 * time.Millisecond)
 		fmt.Println("")
+		select {
+		case c := <-hostClient:
+			saveChatLog(cmd, c)
+		default:
+		}
+		if exportDir, _ := cmd.Flags().GetString("export-git"); exportDir != "" {
+			exportSession(exportDir, sessionStart)
+		}
 		fmt.Println("Goodbye!")
 		
 	},
@@ -130,6 +313,313 @@ Perfect for mock interviews, pair programming, and collaborative debugging.`,
 
 
 
+// startSharedShell runs the host's shell on a pseudo-terminal that peers can
+// watch with 'waveland join --terminal'.
+func startSharedShell(ctx context.Context, con *console.Console) error {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	h, err := sharedterm.Share(ctx, shell)
+	if err != nil {
+		return err
+	}
+	con.Handle("shell", "", "use the shared shell (Ctrl-] to come back)", func([]string) {
+		if err := h.Attach(con); err != nil {
+			fmt.Println("Cannot attach to the shared shell: ", err)
+		}
+	})
+	con.Handle("control", "<name>", "let a participant type into the shared shell", func(args []string) {
+		if len(args) != 1 {
+			fmt.Println("Usage: /control <name>")
+			return
+		}
+		if err := server.SetTerminalControl(args[0], true); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("%s can now type into the shared shell\n", args[0])
+	})
+	con.Handle("revoke", "<name>", "take back control of the shared shell", func(args []string) {
+		if len(args) != 1 {
+			fmt.Println("Usage: /revoke <name>")
+			return
+		}
+		if err := server.SetTerminalControl(args[0], false); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("%s can no longer type into the shared shell\n", args[0])
+	})
+	fmt.Println("Sharing a shell: /shell to use it, peers who joined watch with 'waveland join --terminal <url>'")
+	return nil
+}
+
+func addTimerCommands(con *console.Console) {
+	con.Handle("timer", "[duration]", "show the time left, or restart the timer", func(args []string) {
+		if len(args) == 1 {
+			d, err := time.ParseDuration(args[0])
+			if err != nil || d <= 0 {
+				fmt.Println("Usage: /timer [duration], e.g. /timer 45m")
+				return
+			}
+			server.StartTimer(d)
+			return
+		}
+		t := server.TimerState()
+		switch {
+		case t == nil || t.Duration == 0:
+			fmt.Println("No timer, start one with /timer <duration>")
+		case t.Started.IsZero():
+			fmt.Printf("%s, starts when someone joins\n", t.Duration)
+		default:
+			fmt.Printf("%s left of %s\n", t.Remaining().Round(time.Second), t.Duration)
+		}
+	})
+	con.Handle("phase", "<name>", "mark a new phase: "+strings.Join(server.Phases, ", ")+" or anything else", func(args []string) {
+		if len(args) == 0 {
+			fmt.Println("Usage: /phase <name>")
+			return
+		}
+		server.SetPhase(strings.Join(args, " "))
+	})
+	con.Handle("freeze", "", "make files read-only for everyone else", func([]string) {
+		server.Freeze(true)
+	})
+	con.Handle("unfreeze", "", "let everyone edit again", func([]string) {
+		server.Freeze(false)
+	})
+}
+
+func addMobCommands(con *console.Console) {
+	con.Handle("mob", "[rotate|off]", "mob mode: one driver at a time, e.g. /mob 10m", func(args []string) {
+		if len(args) == 1 && args[0] == "off" {
+			server.StopMob()
+			return
+		}
+		var rotate time.Duration
+		if len(args) == 1 {
+			d, err := time.ParseDuration(args[0])
+			if err != nil {
+				fmt.Println("Usage: /mob [rotate|off], e.g. /mob 10m")
+				return
+			}
+			rotate = d
+		}
+		server.StartMob(rotate)
+	})
+	con.Handle("driver", "<name>", "mob mode: make someone the driver", func(args []string) {
+		if len(args) != 1 {
+			fmt.Println("Usage: /driver <name>")
+			return
+		}
+		if err := server.SetDriver(args[0]); err != nil {
+			fmt.Println(err)
+		}
+	})
+	con.Handle("rotate", "", "mob mode: pass the driver role to the next person", func([]string) {
+		server.RotateDriver()
+	})
+}
+
+func addReviewCommands(con *console.Console, c *client.Client) {
+	parseID := func(args []string) (int, bool) {
+		if len(args) == 0 {
+			return 0, false
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		return id, err == nil
+	}
+	con.Handle("proposals", "", "list suggested changes waiting for you", func([]string) {
+		proposals := c.Proposals()
+		if len(proposals) == 0 {
+			fmt.Println("No pending proposals")
+		}
+		for _, p := range proposals {
+			fmt.Printf("  #%d  %s  %s\n", p.ID, p.From, p.File)
+		}
+	})
+	con.Handle("show", "<id>", "show the diff of a proposal", func(args []string) {
+		id, ok := parseID(args)
+		if !ok {
+			fmt.Println("Usage: /show <id>")
+			return
+		}
+		if err := c.ShowProposal(id); err != nil {
+			fmt.Println(err)
+		}
+	})
+	con.Handle("accept", "<id> [hunk...]", "apply a proposal, or only some of its hunks", func(args []string) {
+		id, ok := parseID(args)
+		if !ok {
+			fmt.Println("Usage: /accept <id> [hunk...]")
+			return
+		}
+		var hunks []int
+		for _, arg := range args[1:] {
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				fmt.Println("Usage: /accept <id> [hunk...]")
+				return
+			}
+			hunks = append(hunks, n)
+		}
+		if err := c.Accept(id, hunks); err != nil {
+			fmt.Println(err)
+		}
+	})
+	con.Handle("reject", "<id>", "turn a proposal down", func(args []string) {
+		id, ok := parseID(args)
+		if !ok {
+			fmt.Println("Usage: /reject <id>")
+			return
+		}
+		if err := c.Reject(id); err != nil {
+			fmt.Println(err)
+		}
+	})
+}
+
+// addHintCommands lets the host release the problem's hints one by one, or
+// hints of their own. Release times go to the journal for the report.
+func addHintCommands(con *console.Console, c *client.Client) {
+	var hints []string
+	if session, err := problem.LoadSession(); err == nil {
+		if p, err := problem.Load(session.Source); err == nil {
+			hints = p.Hints
+			p.Close()
+		}
+	}
+	// given counts all hints, next is the problem's next one
+	given, next := 0, 0
+	if events, err := journal.Session(); err == nil {
+		for _, e := range events {
+			if e.Kind != journal.KindHint {
+				continue
+			}
+			given++
+			if next < len(hints) && e.Text == hints[next] {
+				next++
+			}
+		}
+	}
+
+	con.Handle("hint", "[text]", "release the next hint, or your own", func(args []string) {
+		text := strings.Join(args, " ")
+		fromProblem := text == ""
+		if fromProblem {
+			if next >= len(hints) {
+				fmt.Println("No more hints, give your own with /hint <text>")
+				return
+			}
+			text = hints[next]
+		}
+		if err := c.GiveHint(given+1, text); err != nil {
+			fmt.Println("Failed to give the hint: ", err)
+			return
+		}
+		given++
+		if fromProblem {
+			next++
+		}
+		journal.Add(journal.KindHint, c.Name(), text)
+	})
+	con.Handle("hints", "", "list the problem's hints", func([]string) {
+		if len(hints) == 0 {
+			fmt.Println("This problem has no hints")
+			return
+		}
+		for i, hint := range hints {
+			mark := " "
+			if i < next {
+				mark = "✓"
+			}
+			fmt.Printf("  %s %d. %s\n", mark, i+1, hint)
+		}
+	})
+}
+
+// notesFile is the interviewer's scratch pad, never shared.
+const notesFile = "NOTES.md"
+
+func createNotes() error {
+	if _, err := os.Stat(notesFile); !os.IsNotExist(err) {
+		return err
+	}
+	return os.WriteFile(notesFile, []byte("# Interview notes\n\nOnly you can see this file.\n"), 0644)
+}
+
+// loadProblem writes out the statement and starter code of a problem package
+// and returns the files to share, the solution last.
+func loadProblem(ref, lang string) ([]string, error) {
+	p, err := problem.Load(ref)
+	if err != nil {
+		return nil, err
+	}
+	defer p.Close()
+	lang, err = p.Language(lang)
+	if err != nil {
+		return nil, err
+	}
+	files, err := p.Materialize(".", lang)
+	if err != nil {
+		return nil, err
+	}
+	err = problem.SaveSession(problem.Session{Source: p.Source, Lang: lang, Solution: p.SolutionFile(lang)})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Loaded %s (%s): %s\n", p.Title, lang, strings.Join(files, ", "))
+	return files, nil
+}
+
+func printParticipants(participants []protocol.Participant) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tCONNECTED\tLATENCY")
+	for _, p := range participants {
+		name := p.Name
+		if p.Role != "" {
+			name += " (" + p.Role + ")"
+		} else if p.Host {
+			name += " (host)"
+		}
+		latency := "-"
+		if p.Latency > 0 {
+			latency = p.Latency.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "  %s\t%s ago\t%s\n", name, time.Since(p.Joined).Round(time.Second), latency)
+	}
+	w.Flush()
+}
+
+func exportSession(dir string, since time.Time) {
+	store, err := history.Open(history.DefaultDir)
+	if err != nil {
+		fmt.Println("Failed to export session: ", err)
+		return
+	}
+	commits, err := gitexport.Export(store, dir, gitexport.Options{Since: since})
+	if err != nil {
+		fmt.Println("Failed to export session: ", err)
+		return
+	}
+	fmt.Printf("Exported %d commits to %s\n", commits, dir)
+}
+
 func init() {
 	rootCmd.AddCommand(startCmd)
+	addSessionFlags(startCmd)
+	startCmd.Flags().String("record", "", "record every synced change to this file for 'waveland replay'")
+	startCmd.Flags().String("export-git", "", "export the session as a git repository in this directory on exit")
+	startCmd.Flags().String("on-change", "", `command to run after every batch of changes, e.g. "go test ./..."`)
+	startCmd.Flags().String("problem", "", "problem package to interview with (path or name in ~/.waveland/problems)")
+	startCmd.Flags().String("lang", "", "language of the starter code to use with --problem")
+	startCmd.Flags().Bool("interview", false, "run a mock interview: you are the interviewer, NOTES.md stays private")
+	startCmd.Flags().StringSlice("private", nil, "file names (* wildcards) that are never shared with peers")
+	startCmd.Flags().Duration("duration", 0, "session timer, e.g. 45m, started when the first peer joins")
+	startCmd.Flags().Bool("freeze", false, "make files read-only for peers when the timer runs out")
+	startCmd.Flags().Bool("mob", false, "mob programming: only one driver may change files at a time")
+	startCmd.Flags().Duration("rotate", 0, "with --mob, pass the driver role on this often, e.g. 10m")
+	startCmd.Flags().Bool("review", false, "peers' changes become proposals you accept or reject")
+	startCmd.Flags().Bool("shell", false, "share a shell that peers can watch with 'waveland join --terminal'")
 }
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/console"
+	"github.com/spf13/cobra"
+)
+
+// workspace is the directory a joiner syncs into.
+type workspace struct {
+	dir       string
+	origin    string
+	ephemeral bool
+}
+
+// enterWorkspace changes into the directory given by --dir, or a new
+// temporary one with --ephemeral, and opens it with --edit.
+func enterWorkspace(cmd *cobra.Command) (*workspace, error) {
+	dir, _ := cmd.Flags().GetString("dir")
+	ephemeral, _ := cmd.Flags().GetBool("ephemeral")
+	if dir != "" && ephemeral {
+		return nil, fmt.Errorf("--dir and --ephemeral can't be used together")
+	}
+
+	origin, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	// keep --chat-log relative to where join was run
+	if path, _ := cmd.Flags().GetString("chat-log"); path != "" && !filepath.IsAbs(path) {
+		cmd.Flags().Set("chat-log", filepath.Join(origin, path))
+	}
+
+	w := &workspace{dir: origin, origin: origin, ephemeral: ephemeral}
+	switch {
+	case ephemeral:
+		if w.dir, err = os.MkdirTemp("", "waveland-"); err != nil {
+			return nil, err
+		}
+	case dir != "":
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		w.dir = dir
+	}
+	if err := os.Chdir(w.dir); err != nil {
+		return nil, err
+	}
+	if w.dir != origin {
+		fmt.Printf("Syncing into %s\n", w.dir)
+	}
+
+	if edit, _ := cmd.Flags().GetBool("edit"); edit {
+		w.edit()
+	}
+	return w, nil
+}
+
+// terminalEditors would fight the session console for the terminal, so
+// they are left for the user to run in another one.
+var terminalEditors = []string{"vi", "vim", "nvim", "nano", "pico", "emacs", "micro", "hx", "kak", "joe", "ne", "mg", "ed"}
+
+// edit opens the workspace in $VISUAL, or $EDITOR, without waiting for it.
+// For a terminal editor it prints the command to run instead.
+func (w *workspace) edit() {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		fmt.Printf("Set $VISUAL or $EDITOR to open the workspace, or open %s yourself\n", w.dir)
+		return
+	}
+	fields := strings.Fields(editor)
+	if slices.Contains(terminalEditors, filepath.Base(fields[0])) {
+		fmt.Printf("Open the workspace from another terminal with: %s %s\n", editor, w.dir)
+		return
+	}
+	c := exec.Command(fields[0], append(fields[1:], w.dir)...)
+	if err := c.Start(); err != nil {
+		fmt.Printf("Failed to open %s: %v\n", editor, err)
+		return
+	}
+	go c.Wait()
+}
+
+// close asks what to do with an ephemeral workspace: keep it where it is,
+// archive it next to where join was run, or delete it.
+func (w *workspace) close(con *console.Console) {
+	answer := make(chan string, 1)
+	con.Ask(fmt.Sprintf("Keep, archive or delete %s? [K/a/d] ", w.dir), func(line string) {
+		answer <- strings.ToLower(line)
+	})
+	var choice string
+	select {
+	case choice = <-answer:
+	case <-time.After(time.Minute):
+		fmt.Println("")
+	}
+	os.Chdir(w.origin)
+
+	switch {
+	case strings.HasPrefix(choice, "a"):
+		archive := filepath.Join(w.origin, "waveland-"+time.Now().Format("20060102-150405")+".tar.gz")
+		if err := archiveDir(w.dir, archive); err != nil {
+			fmt.Println("Failed to archive the workspace: ", err)
+			fmt.Printf("Files kept in %s\n", w.dir)
+			return
+		}
+		os.RemoveAll(w.dir)
+		fmt.Printf("Archived to %s\n", archive)
+	case strings.HasPrefix(choice, "d"):
+		if err := os.RemoveAll(w.dir); err != nil {
+			fmt.Println("Failed to delete the workspace: ", err)
+			return
+		}
+		fmt.Printf("Deleted %s\n", w.dir)
+	default:
+		fmt.Printf("Files kept in %s\n", w.dir)
+	}
+}
+
+// archiveDir writes the files under dir to a gzipped tarball at path.
+func archiveDir(dir, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.WalkDir(dir, func(name string, d os.DirEntry, err error) error {
+		if err != nil || name == dir {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, name)
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		src, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
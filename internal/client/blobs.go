@@ -0,0 +1,149 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// blobState tracks content by hash, see protocol.File. Content itself
+// lives in the history store.
+type blobState struct {
+	// server holds the hashes the server has seen from or sent to us
+	server sync.Map
+	mu     sync.Mutex
+	// wanted holds file messages waiting for content asked for
+	wanted map[string][]wantedFile
+	// sent maps content we referred to by hash to the file it was for
+	sent map[string]string
+}
+
+type wantedFile struct {
+	from string
+	file protocol.File
+}
+
+// blob looks content up by hash.
+func (c *Client) blob(hash string) ([]byte, bool) {
+	if c.history == nil {
+		return nil, false
+	}
+	content, err := c.history.Get(hash)
+	return content, err == nil
+}
+
+// outgoing refers to content by hash if the server already has it. Without
+// a history store we couldn't send the content if asked, so it always goes
+// in full.
+func (c *Client) outgoing(name string, content []byte, hash string) protocol.File {
+	_, known := c.blobs.server.LoadOrStore(hash, true)
+	if known && len(content) > 0 && c.history != nil {
+		c.blobs.mu.Lock()
+		if c.blobs.sent == nil {
+			c.blobs.sent = make(map[string]string)
+		}
+		c.blobs.sent[hash] = name
+		c.blobs.mu.Unlock()
+		return protocol.File{Name: name, Hash: hash}
+	}
+	return protocol.File{Name: name, Content: content}
+}
+
+// receiveFileMessage resolves a file sent as a reference before applying
+// it, asking the server for content it doesn't have here.
+func (c *Client) receiveFileMessage(from string, file protocol.File) {
+	if file.Hash != "" {
+		content, ok := c.blob(file.Hash)
+		if !ok {
+			c.want(from, file)
+			return
+		}
+		file.Content, file.Hash = content, ""
+	}
+	c.blobs.server.Store(fileHash(file.Content), true)
+	c.receiveFile(from, file)
+}
+
+func (c *Client) want(from string, file protocol.File) {
+	c.blobs.mu.Lock()
+	if c.blobs.wanted == nil {
+		c.blobs.wanted = make(map[string][]wantedFile)
+	}
+	asked := len(c.blobs.wanted[file.Hash]) > 0
+	c.blobs.wanted[file.Hash] = append(c.blobs.wanted[file.Hash], wantedFile{from: from, file: file})
+	c.blobs.mu.Unlock()
+	if asked {
+		return
+	}
+	if err := c.send(protocol.TypeWant, protocol.Want{Hashes: []string{file.Hash}}); err != nil {
+		log.Println("error asking for content: ", err)
+	}
+}
+
+// receiveBlob applies the file messages that were waiting for blob.
+func (c *Client) receiveBlob(blob protocol.Blob) {
+	if fileHash(blob.Content) != blob.Hash {
+		return
+	}
+	if c.history != nil {
+		c.history.Put(blob.Content)
+	}
+	c.blobs.mu.Lock()
+	pending := c.blobs.wanted[blob.Hash]
+	delete(c.blobs.wanted, blob.Hash)
+	c.blobs.mu.Unlock()
+	for _, w := range pending {
+		w.file.Content, w.file.Hash = blob.Content, ""
+		c.receiveFileMessage(w.from, w.file)
+	}
+}
+
+// sendBlobs answers the server asking for content we referred to. Content
+// we can't find any more is replaced by the file it was for, sent in full.
+func (c *Client) sendBlobs(want protocol.Want) {
+	for _, hash := range want.Hashes {
+		content, ok := c.blob(hash)
+		if !ok {
+			c.blobs.server.Delete(hash)
+			c.blobs.mu.Lock()
+			name := c.blobs.sent[hash]
+			c.blobs.mu.Unlock()
+			if name == "" {
+				log.Printf("asked for content %s we don't have\n", hash)
+				continue
+			}
+			c.resend(name)
+			continue
+		}
+		if err := c.send(protocol.TypeBlob, protocol.Blob{Hash: hash, Content: content}); err != nil {
+			log.Println("error sending content: ", err)
+		}
+	}
+}
+
+// resend sends a file in full.
+func (c *Client) resend(name string) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		log.Printf("error reading %s to send it again: %v\n", name, err)
+		return
+	}
+	c.lastHash.Store(name, fileHash(content))
+	if err := c.send(protocol.TypeFile, protocol.File{Name: name, Content: content}); err != nil {
+		log.Println("error writing the file: ", err)
+	}
+}
+
+// lost gives up on the file messages waiting for content nobody has.
+func (c *Client) lost(r protocol.Rejected) {
+	c.blobs.mu.Lock()
+	pending := c.blobs.wanted[r.Hash]
+	delete(c.blobs.wanted, r.Hash)
+	c.blobs.mu.Unlock()
+	for _, w := range pending {
+		fmt.Printf("✗ %s's change to %s was lost: %s\n", w.from, w.file.Name, r.Reason)
+	}
+}
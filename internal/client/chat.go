@@ -0,0 +1,57 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// chatLog is every chat message this client has seen, for the transcript.
+type chatLog struct {
+	mu    sync.Mutex
+	lines []protocol.Chat
+}
+
+func (l *chatLog) add(chat protocol.Chat) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, chat)
+}
+
+// Say sends a chat message to everyone in the session.
+func (c *Client) Say(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if err := c.send(protocol.TypeChat, protocol.Chat{Text: text}); err != nil {
+		return err
+	}
+	c.chat.add(protocol.Chat{Name: c.Name(), Text: text, Time: time.Now()})
+	return nil
+}
+
+func (c *Client) receiveChat(chat protocol.Chat) {
+	c.chat.add(chat)
+	printChat(chat)
+}
+
+func printChat(chat protocol.Chat) {
+	fmt.Printf("[%s] %s: %s\n", chat.Time.Local().Format("15:04"), chat.Name, chat.Text)
+}
+
+// WriteChatTranscript saves every chat message of the session to path.
+func (c *Client) WriteChatTranscript(path string) error {
+	c.chat.mu.Lock()
+	defer c.chat.mu.Unlock()
+
+	var sb strings.Builder
+	for _, chat := range c.chat.lines {
+		fmt.Fprintf(&sb, "[%s] %s: %s\n", chat.Time.Local().Format("2006-01-02 15:04:05"), chat.Name, chat.Text)
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0644)
+}
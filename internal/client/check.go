@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+const (
+	// checkDelay lets a burst of saves settle before the check runs.
+	checkDelay = 500 * time.Millisecond
+	// checkOutputLines is how much of the output is shared with peers.
+	checkOutputLines = 30
+)
+
+// checker runs the host's --on-change command after each batch of changes,
+// cancelling a run that is made stale by newer changes.
+type checker struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+// scheduleCheck is called after every change this client sends or applies.
+func (c *Client) scheduleCheck() {
+	if !c.isHost() || c.opts.OnChange == "" {
+		return
+	}
+	c.check.mu.Lock()
+	defer c.check.mu.Unlock()
+	if c.check.timer != nil {
+		c.check.timer.Stop()
+	}
+	c.check.timer = time.AfterFunc(checkDelay, c.runCheck)
+}
+
+func (c *Client) runCheck() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.check.mu.Lock()
+	if c.check.cancel != nil {
+		c.check.cancel()
+	}
+	c.check.cancel = cancel
+	c.check.mu.Unlock()
+	defer cancel()
+
+	started := time.Now()
+	cmd := shellCommand(ctx, c.opts.OnChange)
+	cmd.WaitDelay = time.Second
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		// newer changes arrived and started another run
+		return
+	}
+
+	result := protocol.CheckResult{
+		Command:  c.opts.OnChange,
+		Passed:   err == nil,
+		Duration: time.Since(started).Round(10 * time.Millisecond),
+		Output:   lastLines(string(out), checkOutputLines),
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.Code = exitErr.ExitCode()
+	} else if err != nil {
+		result.Code = -1
+		result.Output = err.Error()
+	}
+
+	c.send(protocol.TypeCheckResult, result)
+	c.showCheckResult(result)
+}
+
+func (c *Client) showCheckResult(result protocol.CheckResult) {
+	if result.Passed {
+		fmt.Printf("✓  %s passed (%s)\n", result.Command, result.Duration)
+	} else {
+		fmt.Printf("✗  %s failed with exit status %d (%s)\n", result.Command, result.Code, result.Duration)
+		if result.Output != "" {
+			for _, line := range strings.Split(result.Output, "\n") {
+				fmt.Printf("   | %s\n", line)
+			}
+		}
+	}
+	c.api.Notify("check.result", result)
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("... %d lines omitted\n%s", len(lines)-n, strings.Join(lines[len(lines)-n:], "\n"))
+}
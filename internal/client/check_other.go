@@ -0,0 +1,16 @@
+//go:build !unix
+
+package client
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+)
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// AddComment comments on lines line to end of file.
+func (c *Client) AddComment(file string, line, end int, text string) (protocol.Comment, error) {
+	if line < 1 {
+		return protocol.Comment{}, fmt.Errorf("lines start at 1")
+	}
+	if end < line {
+		end = line
+	}
+	comment := protocol.Comment{
+		ID:      newID(),
+		File:    filepath.Base(file),
+		Line:    line,
+		EndLine: end,
+		Text:    text,
+		Author:  c.Name(),
+		Time:    time.Now(),
+	}
+	return comment, c.updateComment(comment)
+}
+
+// ResolveComment marks a comment resolved, or deletes it.
+func (c *Client) ResolveComment(id string, delete bool) (protocol.Comment, error) {
+	if c.comments == nil {
+		return protocol.Comment{}, fmt.Errorf("comments are disabled")
+	}
+	comment, ok := c.comments.Get(id)
+	if !ok {
+		return comment, fmt.Errorf("no comment %s", id)
+	}
+	comment.Resolved = true
+	comment.Deleted = delete
+	return comment, c.updateComment(comment)
+}
+
+// Comments lists the comments on file, or on all files.
+func (c *Client) Comments(file string) []protocol.Comment {
+	if c.comments == nil {
+		return nil
+	}
+	return c.comments.List(file)
+}
+
+func (c *Client) updateComment(comment protocol.Comment) error {
+	if c.comments != nil {
+		if err := c.comments.Put(comment); err != nil {
+			return err
+		}
+	}
+	return c.send(protocol.TypeComment, comment)
+}
+
+func (c *Client) receiveComment(comment protocol.Comment, announce bool) {
+	if c.comments != nil {
+		if err := c.comments.Put(comment); err != nil {
+			log.Println("error saving comment: ", err)
+		}
+	}
+	c.api.Notify("comment.update", comment)
+	if !announce {
+		return
+	}
+	switch {
+	case comment.Deleted:
+		fmt.Printf("💬 %s's comment on %s was deleted\n", comment.Author, comment.File)
+	case comment.Resolved:
+		fmt.Printf("💬 resolved: %s\n", FormatComment(comment))
+	default:
+		fmt.Printf("💬 %s\n", FormatComment(comment))
+	}
+}
+
+// remapComments moves comments along with a change to file.
+func (c *Client) remapComments(file string, old, new []byte) {
+	if c.comments == nil {
+		return
+	}
+	moved, err := c.comments.Remap(file, old, new)
+	if err != nil {
+		log.Println("error saving comments: ", err)
+	}
+	for _, comment := range moved {
+		c.api.Notify("comment.update", comment)
+	}
+}
+
+// FormatComment renders a comment on one line, e.g.
+// "s.py:3-5 alice: off by one? [1a2b3c4d]".
+func FormatComment(comment protocol.Comment) string {
+	lines := fmt.Sprintf("%d", comment.Line)
+	if comment.EndLine > comment.Line {
+		lines = fmt.Sprintf("%d-%d", comment.Line, comment.EndLine)
+	}
+	var state string
+	switch {
+	case comment.Outdated:
+		state = " (outdated)"
+	case comment.Resolved:
+		state = " (resolved)"
+	}
+	return fmt.Sprintf("%s:%s %s: %s%s [%s]", comment.File, lines, comment.Author, comment.Text, state, comment.ID)
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-johnnyhe/waveland/internal/editorapi"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// startEditorAPI serves the local JSON-RPC socket editor plugins talk to.
+//
+// Methods:
+//   session.info     -> {"name", "following"}
+//   cursor.publish   {"file", "line", "col", "selection"?} share your cursor
+//   cursor.list      -> cursors of everyone else
+//   follow.set       {"name"} follow a participant, "" to stop
+//   run.request      {"id"?, "args"} ask the host to run a command -> {"id"}
+//   timer.get        -> the session timer or null
+//   comment.add      {"file", "line", "end_line"?, "text"} -> the comment
+//   comment.resolve  {"id", "delete"?} resolve or delete a comment
+//   comment.list     {"file"?} -> comments, ordered by file and line
+//   lock.acquire     {"file"} claim a file, answered by lock.update or rejected
+//   lock.release     {"file"}
+//   lock.list        -> [{"file", "owner"}]
+//
+// Notifications:
+//   cursor.update    {"name", "file", "line", "col", "selection"?}
+//   cursor.clear     {"name"} when a participant leaves
+//   follow.open      {"name", "file", "line"} the followed participant moved
+//   follow.changed   {"name"} who is being followed now
+//   run.start        {"id", "args", "by"}
+//   run.output       {"id", "stream", "data"} data is base64
+//   run.exit         {"id", "code", "error"?}
+//   check.result     {"command", "passed", "code", "duration", "output"}
+//   timer.update     {"duration", "started", "phase", "auto_freeze", "frozen"}
+//   hint             {"n", "text", "time"} the interviewer released a hint
+//   driver.update    {"name", "next"?, "rotate"?, "until"?} in mob mode
+//   rejected         {"type", "file"?, "reason"} the server dropped a message
+//   lock.update      {"file", "owner"?} owner is empty when the file is free
+//   proposal         {"id", "from", "file"} review mode, host only
+//   comment.update   {"id", "file", "line", "end_line", "text", "author", ...}
+//                    a comment was added, moved, resolved or deleted
+//   review           {"id", "file", "verdict"} the host answered your change
+func (c *Client) startEditorAPI(ctx context.Context) {
+	if c.opts.EditorSocket == "" {
+		return
+	}
+	api, err := editorapi.Listen(c.opts.EditorSocket)
+	if err != nil {
+		log.Println("editor API disabled: ", err)
+		return
+	}
+
+	api.Handle("session.info", func(json.RawMessage) (any, error) {
+		return map[string]string{"name": c.Name(), "following": c.Following()}, nil
+	})
+	api.Handle("lock.acquire", func(params json.RawMessage) (any, error) {
+		var lock protocol.Lock
+		if err := json.Unmarshal(params, &lock); err != nil || lock.File == "" {
+			return nil, fmt.Errorf("missing file")
+		}
+		return nil, c.Lock(lock.File)
+	})
+	api.Handle("lock.release", func(params json.RawMessage) (any, error) {
+		var lock protocol.Lock
+		if err := json.Unmarshal(params, &lock); err != nil || lock.File == "" {
+			return nil, fmt.Errorf("missing file")
+		}
+		return nil, c.Unlock(lock.File)
+	})
+	api.Handle("lock.list", func(json.RawMessage) (any, error) {
+		return c.Locks(), nil
+	})
+	api.Handle("comment.add", func(params json.RawMessage) (any, error) {
+		var comment protocol.Comment
+		if err := json.Unmarshal(params, &comment); err != nil {
+			return nil, err
+		}
+		if comment.File == "" || comment.Text == "" {
+			return nil, fmt.Errorf("missing file or text")
+		}
+		return c.AddComment(comment.File, comment.Line, comment.EndLine, comment.Text)
+	})
+	api.Handle("comment.resolve", func(params json.RawMessage) (any, error) {
+		var req struct {
+			ID     string `json:"id"`
+			Delete bool   `json:"delete"`
+		}
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		return c.ResolveComment(req.ID, req.Delete)
+	})
+	api.Handle("comment.list", func(params json.RawMessage) (any, error) {
+		var req struct {
+			File string `json:"file"`
+		}
+		json.Unmarshal(params, &req)
+		if req.File != "" {
+			req.File = filepath.Base(req.File)
+		}
+		return c.Comments(req.File), nil
+	})
+	api.Handle("timer.get", func(json.RawMessage) (any, error) {
+		return c.Timer(), nil
+	})
+	api.Handle("cursor.publish", func(params json.RawMessage) (any, error) {
+		var cursor protocol.Cursor
+		if err := json.Unmarshal(params, &cursor); err != nil {
+			return nil, err
+		}
+		if cursor.File == "" {
+			return nil, fmt.Errorf("missing file")
+		}
+		cursor.Name = ""
+		cursor.File = filepath.Base(cursor.File)
+		return nil, c.send(protocol.TypeCursor, cursor)
+	})
+	api.Handle("cursor.list", func(json.RawMessage) (any, error) {
+		cursors := []protocol.Cursor{}
+		c.cursors.Range(func(_, v any) bool {
+			cursors = append(cursors, v.(protocol.Cursor))
+			return true
+		})
+		sort.Slice(cursors, func(i, j int) bool {
+			return cursors[i].Name < cursors[j].Name
+		})
+		return cursors, nil
+	})
+
+	api.Handle("follow.set", func(params json.RawMessage) (any, error) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		c.Follow(req.Name)
+		return nil, nil
+	})
+
+	api.Handle("run.request", func(params json.RawMessage) (any, error) {
+		var req protocol.RunRequest
+		if err := json.Unmarshal(params, &req); err != nil {
+			return nil, err
+		}
+		id, err := c.RequestRun(req.ID, req.Args)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"id": id}, nil
+	})
+
+	c.api = api
+	go api.Serve(ctx)
+}
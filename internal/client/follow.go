@@ -0,0 +1,94 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// followState remembers where the followed participant was last seen.
+type followState struct {
+	mu   sync.Mutex
+	name string
+	file string
+	line int
+}
+
+// Follow makes this client track name's most recently edited file. An empty
+// name stops following.
+func (c *Client) Follow(name string) {
+	c.follow.mu.Lock()
+	c.follow.name = name
+	c.follow.file = ""
+	c.follow.line = 0
+	c.follow.mu.Unlock()
+
+	if name == "" {
+		fmt.Println("Stopped following")
+	} else {
+		fmt.Printf("Following %s\n", name)
+	}
+	c.api.Notify("follow.changed", map[string]string{"name": name})
+}
+
+func (c *Client) Following() string {
+	c.follow.mu.Lock()
+	defer c.follow.mu.Unlock()
+	return c.follow.name
+}
+
+// observe is called whenever a participant edits a file or moves their cursor.
+// If we follow them, the editor is told to go to the same place; the open
+// command only runs when they switch files so it doesn't steal focus on
+// every keystroke.
+func (c *Client) observe(name, file string, line int) {
+	c.follow.mu.Lock()
+	if name == "" || name != c.follow.name {
+		c.follow.mu.Unlock()
+		return
+	}
+	switched := file != c.follow.file
+	c.follow.file = file
+	c.follow.line = line
+	c.follow.mu.Unlock()
+
+	c.api.Notify("follow.open", map[string]any{"name": name, "file": file, "line": line})
+	if switched {
+		fmt.Printf("~> %s is in %s:%d\n", name, file, line)
+		if c.opts.OpenCommand != "" {
+			c.runOpenCommand(file, line)
+		}
+	}
+}
+
+// runOpenCommand runs the --open-cmd template, e.g. "code -g {file}:{line}".
+// The file name comes from a peer, so the template is split into arguments
+// before it is filled in and runs without a shell.
+func (c *Client) runOpenCommand(file string, line int) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		path = file
+	}
+	args := strings.Fields(c.opts.OpenCommand)
+	if len(args) == 0 {
+		return
+	}
+	r := strings.NewReplacer("{file}", path, "{line}", strconv.Itoa(max(line, 1)))
+	for i, arg := range args {
+		args[i] = r.Replace(arg)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		log.Printf("error running open command: %v\n", err)
+		return
+	}
+	go cmd.Wait()
+}
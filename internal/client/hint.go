@@ -0,0 +1,72 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// HintsFile collects the hints released during the session.
+const HintsFile = "HINTS.md"
+
+// GiveHint releases hint n to everyone: it is appended to HINTS.md, which
+// is sent along with the hint, and shown in their terminals.
+func (c *Client) GiveHint(n int, text string) error {
+	hint := protocol.Hint{N: n, Text: text, Time: time.Now()}
+
+	f, err := os.OpenFile(HintsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() == 0 {
+		fmt.Fprintln(f, "# Hints")
+	}
+	_, err = fmt.Fprintf(f, "\n<!-- hint %d, %s -->\n%s\n", n, hint.Time.Local().Format("15:04"), quote(text))
+	f.Close()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(HintsFile)
+	if err != nil {
+		return err
+	}
+	if c.isPrivate(HintsFile) {
+		fmt.Printf("%s is private, the hint is only shown in terminals\n", HintsFile)
+	} else {
+		hint.File = content
+		c.lastHash.Store(HintsFile, fileHash(content))
+		c.tree.Set(HintsFile, fileHash(content))
+	}
+
+	if err := c.send(protocol.TypeHint, hint); err != nil {
+		return err
+	}
+	if hint.File != nil {
+		c.recordVersion(HintsFile, content, c.Name())
+	}
+	showHint(hint)
+	return nil
+}
+
+func (c *Client) receiveHint(from string, hint protocol.Hint) {
+	if hint.File != nil {
+		c.receiveFile(from, protocol.File{Name: HintsFile, Content: hint.File})
+	}
+	showHint(hint)
+	c.api.Notify("hint", hint)
+}
+
+func showHint(hint protocol.Hint) {
+	fmt.Printf("💡 Hint %d: %s\n", hint.N, hint.Text)
+}
+
+func quote(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
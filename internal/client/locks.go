@@ -0,0 +1,49 @@
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// Lock claims file for this participant; the server announces the result.
+func (c *Client) Lock(file string) error {
+	return c.send(protocol.TypeLock, protocol.Lock{File: filepath.Base(file)})
+}
+
+func (c *Client) Unlock(file string) error {
+	return c.send(protocol.TypeUnlock, protocol.Lock{File: filepath.Base(file)})
+}
+
+// Locks lists the locked files and who holds them.
+func (c *Client) Locks() []protocol.Lock {
+	list := []protocol.Lock{}
+	c.locks.Range(func(k, v any) bool {
+		list = append(list, protocol.Lock{File: k.(string), Owner: v.(string)})
+		return true
+	})
+	sort.Slice(list, func(i, j int) bool { return list[i].File < list[j].File })
+	return list
+}
+
+func (c *Client) applyLock(lock protocol.Lock, announce bool) {
+	if lock.Owner == "" {
+		c.locks.Delete(lock.File)
+	} else {
+		c.locks.Store(lock.File, lock.Owner)
+	}
+	c.api.Notify("lock.update", lock)
+	if !announce {
+		return
+	}
+	switch {
+	case lock.Owner == "":
+		fmt.Printf("🔓 %s is free\n", lock.File)
+	case lock.Owner == c.Name():
+		fmt.Printf("🔒 you locked %s\n", lock.File)
+	default:
+		fmt.Printf("🔒 %s locked %s\n", lock.Owner, lock.File)
+	}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// mobState is who drives in mob mode; only the driver's changes are sent.
+type mobState struct {
+	mu     sync.Mutex
+	driver string
+	warned bool
+}
+
+func (c *Client) applyDriver(d protocol.Driver) {
+	c.mob.mu.Lock()
+	changed := d.Name != c.mob.driver
+	c.mob.driver = d.Name
+	c.mob.warned = false
+	c.mob.mu.Unlock()
+	c.api.Notify("driver.update", d)
+	if !changed {
+		return
+	}
+
+	switch {
+	case d.Name == "":
+		fmt.Println("🚗 Mob mode is over, everyone can edit")
+		return
+	case d.Name == c.Name():
+		fmt.Println("🚗 You are driving now")
+	default:
+		fmt.Printf("🚗 %s is driving now, you navigate\n", d.Name)
+	}
+	if d.Next != "" && !d.Until.IsZero() {
+		fmt.Printf("   %s takes over in %s\n", d.Next, formatRemaining(time.Until(d.Until)))
+	}
+}
+
+// Driver is who drives in mob mode, or "" outside it.
+func (c *Client) Driver() string {
+	c.mob.mu.Lock()
+	defer c.mob.mu.Unlock()
+	return c.mob.driver
+}
+
+// navigating reports whether someone else drives, telling the user the first
+// time one of their changes is held back.
+func (c *Client) navigating() bool {
+	c.mob.mu.Lock()
+	defer c.mob.mu.Unlock()
+	if c.mob.driver == "" || c.mob.driver == c.Name() {
+		return false
+	}
+	if !c.mob.warned {
+		c.mob.warned = true
+		fmt.Printf("%s is driving, your changes are not shared\n", c.mob.driver)
+	}
+	return true
+}
+
+// Handoff passes the driver role to name, or to whoever is next.
+func (c *Client) Handoff(name string) error {
+	return c.send(protocol.TypeHandoff, protocol.Handoff{To: name})
+}
+
+func (c *Client) receiveRejected(r protocol.Rejected) {
+	switch {
+	case r.Type == protocol.TypeWant:
+		c.lost(r)
+	case r.Type == protocol.TypeFile:
+		// so that saving it again sends it again
+		c.lastHash.Delete(r.File)
+		fmt.Printf("✗ %s was not shared: %s\n", r.File, r.Reason)
+	case r.File != "":
+		fmt.Printf("✗ cannot %s %s: %s\n", r.Type, r.File, r.Reason)
+	default:
+		fmt.Printf("✗ %s\n", r.Reason)
+	}
+	c.api.Notify("rejected", r)
+}
@@ -0,0 +1,263 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/merkle"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// How a joiner settles files that differ from the host's.
+const (
+	PreferHost   = "host"
+	PreferLocal  = "local"
+	PreferNewest = "newest"
+	PreferAsk    = "ask"
+)
+
+// Prefers lists the valid Options.Prefer values.
+var Prefers = []string{PreferHost, PreferLocal, PreferNewest, PreferAsk}
+
+// buildTree hashes the shared files in the session directory. From then on
+// the tree follows the file events and received files.
+func (c *Client) buildTree() {
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		c.track(e.Name())
+	}
+}
+
+// track updates the hash tree for a file that changed or went away.
+func (c *Client) track(name string) {
+	if ignore.MatchString(name) || c.isPrivate(name) {
+		return
+	}
+	info, err := os.Stat(name)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > 10*1024*1024 {
+		c.tree.Delete(name)
+		return
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return
+	}
+	c.tree.Set(name, fileHash(content))
+}
+
+// entries lists the files in the given buckets, with their modification
+// times for --prefer newest.
+func (c *Client) entries(buckets []string) map[string]protocol.ManifestEntry {
+	files := make(map[string]protocol.ManifestEntry)
+	for _, key := range buckets {
+		for name, hash := range c.tree.Files(key) {
+			entry := protocol.ManifestEntry{Name: name, Hash: hash}
+			if info, err := os.Stat(name); err == nil {
+				entry.ModTime = info.ModTime()
+			}
+			files[name] = entry
+		}
+	}
+	return files
+}
+
+// answerManifest is the host's side of a joiner's reconciliation: it lists
+// the files in the buckets that differ, or sends the files asked for.
+func (c *Client) answerManifest(to string, theirs protocol.Manifest) {
+	if len(theirs.Want) > 0 {
+		c.sendWanted(to, theirs.Want)
+		return
+	}
+	reply := protocol.Manifest{To: to, Root: c.tree.Root(), Buckets: c.tree.Buckets()}
+	if reply.Root != theirs.Root {
+		for _, f := range c.entries(merkle.Diff(reply.Buckets, theirs.Buckets)) {
+			reply.Files = append(reply.Files, f)
+		}
+	}
+	if err := c.send(protocol.TypeManifest, reply); err != nil {
+		log.Println("error sending the manifest: ", err)
+	}
+}
+
+// sendWanted sends the named shared files to a joiner only.
+func (c *Client) sendWanted(to string, names []string) {
+	for _, name := range names {
+		if filepath.Base(name) != name || ignore.MatchString(name) || c.isPrivate(name) {
+			continue
+		}
+		content, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		file := c.outgoing(name, content, fileHash(content))
+		file.To = to
+		if err := c.send(protocol.TypeFile, file); err != nil {
+			log.Println("error sending files: ", err)
+			return
+		}
+	}
+}
+
+// reconcile compares this directory with the host's before live sync
+// starts, prints what it is going to do and does it. Only the buckets whose
+// hashes differ are listed, and only the files taken from the host are
+// transferred. It runs once, when joining.
+func (c *Client) reconcile(ctx context.Context) {
+	root, buckets := c.tree.Root(), c.tree.Buckets()
+	if err := c.send(protocol.TypeManifest, protocol.Manifest{Root: root, Buckets: buckets}); err != nil {
+		log.Println("error sending the manifest: ", err)
+		return
+	}
+	answer, ok := c.awaitManifest(ctx)
+	if !ok {
+		return
+	}
+	if answer.Root == root {
+		fmt.Println("Your files match the host's")
+		return
+	}
+
+	differ := merkle.Diff(buckets, answer.Buckets)
+	mine := c.entries(differ)
+	same := 0
+	for key, hash := range buckets {
+		if answer.Buckets[key] == hash {
+			same += len(c.tree.Files(key))
+		}
+	}
+	sort.Slice(answer.Files, func(i, j int) bool { return answer.Files[i].Name < answer.Files[j].Name })
+
+	var want, upload, keep, summary []string
+	for _, f := range answer.Files {
+		var local *protocol.ManifestEntry
+		if l, ok := mine[f.Name]; ok {
+			local = &l
+		}
+		delete(mine, f.Name)
+		switch c.decide(local, &f) {
+		case actionSame:
+			c.lastHash.Store(f.Name, f.Hash)
+			same++
+		case actionDownload:
+			want = append(want, f.Name)
+			if local == nil {
+				summary = append(summary, fmt.Sprintf("   <- %s (new)", f.Name))
+			} else {
+				summary = append(summary, fmt.Sprintf("   <- %s (overwrites yours)", f.Name))
+			}
+		default:
+			upload = append(upload, f.Name)
+			summary = append(summary, fmt.Sprintf("   -> %s (replaces the host's)", f.Name))
+		}
+	}
+	names := make([]string, 0, len(mine))
+	for name := range mine {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l := mine[name]
+		if c.decide(&l, nil) == actionUpload {
+			upload = append(upload, name)
+			summary = append(summary, fmt.Sprintf("   -> %s (new)", name))
+		} else {
+			keep = append(keep, name)
+			c.kept.Store(name, true)
+			summary = append(summary, fmt.Sprintf("   =  %s (kept here, not shared)", name))
+		}
+	}
+
+	fmt.Printf("Reconciled with the host, preferring %s: %d unchanged, %d to download, %d to upload, %d kept\n",
+		c.opts.Prefer, same, len(want), len(upload), len(keep))
+	for _, line := range summary {
+		fmt.Println(line)
+	}
+
+	// the files come as file messages, applied as they arrive
+	if len(want) > 0 {
+		if err := c.send(protocol.TypeManifest, protocol.Manifest{Want: want}); err != nil {
+			log.Println("error asking for files: ", err)
+		}
+	}
+	for _, name := range upload {
+		c.SendFile(name)
+	}
+}
+
+// awaitManifest waits for the host's next manifest message.
+func (c *Client) awaitManifest(ctx context.Context) (protocol.Manifest, bool) {
+	var m protocol.Manifest
+	select {
+	case msg := <-c.manifests:
+		return m, msg.Unmarshal(&m) == nil
+	case <-time.After(10 * time.Second):
+		fmt.Println("The host didn't answer, syncing without reconciling")
+	case <-ctx.Done():
+	}
+	return m, false
+}
+
+// What reconcile does with a file.
+type action int
+
+const (
+	actionSame action = iota
+	actionDownload
+	actionUpload
+	actionKeep
+)
+
+// decide settles a file that this side, the host or both have; local or
+// host is nil for a file only the other side has.
+func (c *Client) decide(local, host *protocol.ManifestEntry) action {
+	switch {
+	case host == nil && c.shareLocal(local.Name):
+		return actionUpload
+	case host == nil:
+		return actionKeep
+	case local == nil:
+		return actionDownload
+	case local.Hash == host.Hash:
+		return actionSame
+	case c.takeHost(*local, *host):
+		return actionDownload
+	}
+	return actionUpload
+}
+
+// takeHost decides a file both sides have with different content.
+func (c *Client) takeHost(local, host protocol.ManifestEntry) bool {
+	switch c.opts.Prefer {
+	case PreferLocal:
+		return false
+	case PreferNewest:
+		return host.ModTime.After(local.ModTime)
+	case PreferAsk:
+		if c.opts.Approve != nil {
+			return c.opts.Approve(fmt.Sprintf("%s differs from the host's, take the host's version? (no sends yours)", local.Name))
+		}
+	}
+	return true
+}
+
+// shareLocal decides a file only this side has.
+func (c *Client) shareLocal(name string) bool {
+	switch c.opts.Prefer {
+	case PreferHost:
+		return false
+	case PreferAsk:
+		if c.opts.Approve != nil {
+			return c.opts.Approve(fmt.Sprintf("The host has no %s, share yours?", name))
+		}
+		return false
+	}
+	return true
+}
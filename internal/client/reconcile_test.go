@@ -0,0 +1,50 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+func TestDecide(t *testing.T) {
+	older, newer := time.Unix(1000, 0), time.Unix(2000, 0)
+	mine := &protocol.ManifestEntry{Name: "main.py", Hash: "mine", ModTime: newer}
+	theirs := &protocol.ManifestEntry{Name: "main.py", Hash: "theirs", ModTime: older}
+	same := &protocol.ManifestEntry{Name: "main.py", Hash: "mine", ModTime: older}
+	newerTheirs := &protocol.ManifestEntry{Name: "main.py", Hash: "theirs", ModTime: newer.Add(time.Hour)}
+
+	tests := []struct {
+		prefer  string
+		approve bool
+		local   *protocol.ManifestEntry
+		host    *protocol.ManifestEntry
+		want    action
+	}{
+		{PreferHost, false, mine, nil, actionKeep},
+		{PreferHost, false, nil, theirs, actionDownload},
+		{PreferHost, false, mine, theirs, actionDownload},
+		{PreferHost, false, mine, same, actionSame},
+
+		{PreferLocal, false, mine, nil, actionUpload},
+		{PreferLocal, false, nil, theirs, actionDownload},
+		{PreferLocal, false, mine, theirs, actionUpload},
+
+		{PreferNewest, false, mine, nil, actionUpload},
+		{PreferNewest, false, nil, theirs, actionDownload},
+		{PreferNewest, false, mine, theirs, actionUpload},
+		{PreferNewest, false, mine, newerTheirs, actionDownload},
+
+		{PreferAsk, true, mine, nil, actionUpload},
+		{PreferAsk, false, mine, nil, actionKeep},
+		{PreferAsk, false, nil, theirs, actionDownload},
+		{PreferAsk, true, mine, theirs, actionDownload},
+		{PreferAsk, false, mine, theirs, actionUpload},
+	}
+	for _, tt := range tests {
+		c := &Client{opts: Options{Prefer: tt.prefer, Approve: func(string) bool { return tt.approve }}}
+		if got := c.decide(tt.local, tt.host); got != tt.want {
+			t.Errorf("prefer %s, approve %v: decide(%v, %v) = %v, want %v", tt.prefer, tt.approve, tt.local, tt.host, got, tt.want)
+		}
+	}
+}
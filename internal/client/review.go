@@ -0,0 +1,169 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/go-johnnyhe/waveland/internal/diff"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// reviewState holds the proposals waiting for the host. A newer proposal
+// from the same person for the same file replaces the pending one.
+type reviewState struct {
+	mu      sync.Mutex
+	next    int
+	pending map[int]*Proposal
+}
+
+type Proposal struct {
+	ID      int
+	From    string
+	File    string
+	Content []byte
+}
+
+// hunks diffs the proposal against the host's current file.
+func (p *Proposal) hunks() ([]string, []diff.Hunk) {
+	current, _ := os.ReadFile(p.File)
+	a := diff.Lines(current)
+	return a, diff.Hunks(diff.Compute(a, diff.Lines(p.Content)), 3)
+}
+
+func (c *Client) receiveProposal(from string, file protocol.File) {
+	if !c.isHost() {
+		return
+	}
+	name := filepath.Base(file.Name)
+	if ignore.MatchString(name) || c.isPrivate(name) {
+		return
+	}
+	current, _ := os.ReadFile(name)
+	if bytes.Equal(current, file.Content) {
+		return
+	}
+
+	c.review.mu.Lock()
+	if c.review.pending == nil {
+		c.review.pending = make(map[int]*Proposal)
+	}
+	var p *Proposal
+	for _, pending := range c.review.pending {
+		if pending.From == from && pending.File == name {
+			p = pending
+		}
+	}
+	if p == nil {
+		c.review.next++
+		p = &Proposal{ID: c.review.next, From: from, File: name}
+		c.review.pending[p.ID] = p
+	}
+	p.Content = file.Content
+	proposal := *p
+	c.review.mu.Unlock()
+
+	fmt.Printf("✎ %s proposes a change to %s (#%d)\n", from, name, proposal.ID)
+	printProposal(&proposal)
+	fmt.Printf("   /accept %d [hunk...] or /reject %d\n", proposal.ID, proposal.ID)
+	c.api.Notify("proposal", map[string]any{"id": proposal.ID, "from": from, "file": name})
+}
+
+func printProposal(p *Proposal) {
+	_, hunks := p.hunks()
+	for i, h := range hunks {
+		fmt.Printf("   hunk %d\n", i+1)
+		fmt.Print(h.String())
+	}
+}
+
+// Proposals lists the pending proposals, oldest first.
+func (c *Client) Proposals() []Proposal {
+	c.review.mu.Lock()
+	defer c.review.mu.Unlock()
+	list := make([]Proposal, 0, len(c.review.pending))
+	for _, p := range c.review.pending {
+		list = append(list, *p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// ShowProposal prints the diff of a pending proposal.
+func (c *Client) ShowProposal(id int) error {
+	p, err := c.proposal(id, false)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("#%d from %s: %s\n", p.ID, p.From, p.File)
+	printProposal(p)
+	return nil
+}
+
+func (c *Client) proposal(id int, take bool) (*Proposal, error) {
+	c.review.mu.Lock()
+	defer c.review.mu.Unlock()
+	p, ok := c.review.pending[id]
+	if !ok {
+		return nil, fmt.Errorf("no pending proposal #%d", id)
+	}
+	if take {
+		delete(c.review.pending, id)
+	}
+	return p, nil
+}
+
+// Accept applies a proposal, or only the given hunks (1-based) of it, and
+// shares the result like any other change.
+func (c *Client) Accept(id int, only []int) error {
+	p, err := c.proposal(id, true)
+	if err != nil {
+		return err
+	}
+	a, hunks := p.hunks()
+	selected := make(map[int]bool)
+	for _, n := range only {
+		if n < 1 || n > len(hunks) {
+			c.review.mu.Lock()
+			c.review.pending[id] = p
+			c.review.mu.Unlock()
+			return fmt.Errorf("#%d has %d hunks", id, len(hunks))
+		}
+		selected[n-1] = true
+	}
+	content := diff.Apply(a, hunks, func(i int) bool {
+		return len(only) == 0 || selected[i]
+	})
+
+	if err := os.WriteFile(p.File, content, 0644); err != nil {
+		return err
+	}
+	c.SendFile(p.File)
+
+	verdict := protocol.ReviewAccepted
+	if len(only) > 0 && len(only) < len(hunks) {
+		verdict = protocol.ReviewPartial
+	}
+	fmt.Printf("✓ %s #%d to %s\n", verdict, id, p.File)
+	return c.send(protocol.TypeReview, protocol.Review{To: p.From, ID: id, File: p.File, Verdict: verdict, Content: content, Accepted: only})
+}
+
+// Reject drops a proposal and sends the proposer back the host's version.
+func (c *Client) Reject(id int) error {
+	p, err := c.proposal(id, true)
+	if err != nil {
+		return err
+	}
+	current, _ := os.ReadFile(p.File)
+	fmt.Printf("✗ rejected #%d to %s\n", id, p.File)
+	return c.send(protocol.TypeReview, protocol.Review{To: p.From, ID: id, File: p.File, Verdict: protocol.ReviewRejected, Content: current})
+}
+
+func (c *Client) receiveReview(from string, review protocol.Review) {
+	fmt.Printf("%s %s your change to %s\n", from, review.Verdict, review.File)
+	c.api.Notify("review", map[string]any{"id": review.ID, "file": review.File, "verdict": review.Verdict})
+	c.receiveFile(from, protocol.File{Name: review.File, Content: review.Content})
+}
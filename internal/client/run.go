@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/config"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// runTimeout stops commands that peers started and forgot about.
+const runTimeout = 10 * time.Minute
+
+func newID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (c *Client) isHost() bool {
+	return c.opts.HostToken != ""
+}
+
+// RequestRun asks the host to run args in the session directory. The host
+// runs its own requests straight away; everyone else's need approval unless
+// they match the allowlist. An empty id gets a generated one.
+func (c *Client) RequestRun(id string, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", errors.New("nothing to run")
+	}
+	if id == "" {
+		id = newID()
+	}
+	if c.isHost() {
+		go c.execute(c.Name(), id, args)
+		return id, nil
+	}
+	return id, c.send(protocol.TypeRunRequest, protocol.RunRequest{ID: id, Args: args})
+}
+
+func (c *Client) handleRunRequest(from string, req protocol.RunRequest) {
+	if !c.isHost() || len(req.Args) == 0 {
+		return
+	}
+	command := strings.Join(req.Args, " ")
+	approved := config.Allowed(c.opts.RunAllow, req.Args)
+	if !approved && c.opts.Approve != nil {
+		approved = c.opts.Approve(fmt.Sprintf("%s wants to run `%s`, allow?", from, command))
+	}
+	if !approved {
+		fmt.Printf("Declined `%s` for %s\n", command, from)
+		exit := protocol.RunExit{ID: req.ID, Code: -1, Error: "declined by the host"}
+		c.send(protocol.TypeRunExit, exit)
+		return
+	}
+	c.execute(from, req.ID, req.Args)
+}
+
+// execute runs a command on the host and streams its output to everyone.
+func (c *Client) execute(by, id string, args []string) {
+	start := protocol.RunStart{ID: id, Args: args, By: by}
+	c.send(protocol.TypeRunStart, start)
+	c.showRunStart(start)
+
+	exit := protocol.RunExit{ID: id}
+	defer func() {
+		c.send(protocol.TypeRunExit, exit)
+		c.showRunExit(exit)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		exit.Code, exit.Error = -1, err.Error()
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		exit.Code, exit.Error = -1, err.Error()
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		exit.Code, exit.Error = -1, err.Error()
+		return
+	}
+
+	var wg sync.WaitGroup
+	stream := func(name string, r io.Reader) {
+		defer wg.Done()
+		buf := make([]byte, 8*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				out := protocol.RunOutput{ID: id, Stream: name, Data: append([]byte(nil), buf[:n]...)}
+				if err := c.send(protocol.TypeRunOutput, out); err != nil {
+					log.Println("error sending command output: ", err)
+				}
+				c.showRunOutput(out)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go stream("stdout", stdout)
+	go stream("stderr", stderr)
+	wg.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exit.Code = exitErr.ExitCode()
+		} else {
+			exit.Code, exit.Error = -1, err.Error()
+		}
+		if ctx.Err() != nil {
+			exit.Error = fmt.Sprintf("timed out after %s", runTimeout)
+		}
+	}
+}
+
+func (c *Client) showRunStart(start protocol.RunStart) {
+	fmt.Printf("$ %s   (for %s)\n", strings.Join(start.Args, " "), start.By)
+	c.api.Notify("run.start", start)
+}
+
+func (c *Client) showRunOutput(out protocol.RunOutput) {
+	if out.Stream == "stderr" {
+		os.Stderr.Write(out.Data)
+	} else {
+		os.Stdout.Write(out.Data)
+	}
+	c.api.Notify("run.output", out)
+}
+
+func (c *Client) showRunExit(exit protocol.RunExit) {
+	switch {
+	case exit.Error != "":
+		fmt.Printf("$ command failed: %s\n", exit.Error)
+	default:
+		fmt.Printf("$ exit status %d\n", exit.Code)
+	}
+	c.api.Notify("run.exit", exit)
+}
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// Name is the name the server settled on for this client, which may differ
+// from the requested one when it was already taken.
+func (c *Client) Name() string {
+	if name, ok := c.name.Load().(string); ok {
+		return name
+	}
+	return c.opts.Name
+}
+
+func (c *Client) send(msgType string, payload any) error {
+	out, err := protocol.Encode(msgType, payload)
+	if err != nil {
+		return err
+	}
+	return c.conn.Write(websocket.TextMessage, out)
+}
+
+// serverOnly are the message types the server sends itself, without a
+// sender. The server doesn't relay them from participants, and a copy that
+// names one is ignored all the same.
+var serverOnly = map[string]bool{
+	protocol.TypeWelcome:  true,
+	protocol.TypeJoin:     true,
+	protocol.TypeLeave:    true,
+	protocol.TypeRejected: true,
+	protocol.TypeLock:     true,
+	protocol.TypeWant:     true,
+	protocol.TypeBlob:     true,
+	protocol.TypeTimer:    true,
+	protocol.TypeDriver:   true,
+}
+
+func (c *Client) handleMessage(msg protocol.Message) {
+	if serverOnly[msg.Type] && msg.From != "" {
+		log.Printf("ignoring %s from %s\n", msg.Type, msg.From)
+		return
+	}
+	switch msg.Type {
+	case protocol.TypeFile:
+		var file protocol.File
+		if err := msg.Unmarshal(&file); err != nil {
+			log.Printf("error decoding file from %s: %v\n", msg.From, err)
+			return
+		}
+		c.receiveFileMessage(msg.From, file)
+
+	case protocol.TypeWant:
+		var want protocol.Want
+		if msg.Unmarshal(&want) == nil {
+			go c.sendBlobs(want)
+		}
+
+	case protocol.TypeBlob:
+		var blob protocol.Blob
+		if msg.Unmarshal(&blob) == nil {
+			c.receiveBlob(blob)
+		}
+
+	case protocol.TypeManifest:
+		var m protocol.Manifest
+		if err := msg.Unmarshal(&m); err != nil {
+			return
+		}
+		if c.isHost() {
+			go c.answerManifest(msg.From, m)
+			return
+		}
+		select {
+		case c.manifests <- msg:
+		default:
+		}
+
+	case protocol.TypeWelcome:
+		var welcome protocol.Welcome
+		if err := msg.Unmarshal(&welcome); err != nil {
+			return
+		}
+		c.name.Store(welcome.Name)
+		if welcome.Name != c.opts.Name {
+			fmt.Printf("Joined as %s (%s was taken)\n", welcome.Name, c.opts.Name)
+		}
+		if welcome.TerminalToken != "" && c.opts.TerminalTokenFile != "" {
+			os.MkdirAll(filepath.Dir(c.opts.TerminalTokenFile), 0700)
+			if err := os.WriteFile(c.opts.TerminalTokenFile, []byte(welcome.TerminalToken), 0600); err != nil {
+				log.Println("error saving the terminal token: ", err)
+			}
+		}
+		if len(welcome.Private) > 0 {
+			patterns, _ := c.private.Load().([]string)
+			c.private.Store(append(append([]string(nil), patterns...), welcome.Private...))
+		}
+		if welcome.Timer != nil {
+			c.applyTimer(*welcome.Timer)
+		}
+		if welcome.Driver != nil {
+			c.applyDriver(*welcome.Driver)
+		}
+		for _, lock := range welcome.Locks {
+			c.applyLock(lock, false)
+		}
+		for _, comment := range welcome.Comments {
+			c.receiveComment(comment, false)
+		}
+		if welcome.Review && !c.isHost() {
+			fmt.Println("Review mode: your changes go to the host as suggestions")
+		}
+		if welcome.Role == protocol.RoleCandidate {
+			fmt.Println("This is a mock interview, you are the candidate. Good luck!")
+		}
+		for _, p := range welcome.Participants {
+			if p.Name != welcome.Name {
+				name := p.Name
+				if p.Role != "" {
+					name += " (" + p.Role + ")"
+				}
+				fmt.Printf("   %s is here (since %s)\n", name, p.Joined.Local().Format("15:04"))
+			}
+		}
+		if len(welcome.Chat) > 0 {
+			fmt.Println("--- earlier chat ---")
+			for _, chat := range welcome.Chat {
+				c.receiveChat(chat)
+			}
+			fmt.Println("--------------------")
+		}
+
+	case protocol.TypeJoin, protocol.TypeLeave:
+		var p protocol.Participant
+		if err := msg.Unmarshal(&p); err != nil {
+			return
+		}
+		if msg.Type == protocol.TypeJoin {
+			fmt.Printf("+  %s joined\n", p.Name)
+		} else {
+			fmt.Printf("-  %s left after %s\n", p.Name, time.Since(p.Joined).Round(time.Second))
+			c.cursors.Delete(p.Name)
+			c.api.Notify("cursor.clear", map[string]string{"name": p.Name})
+		}
+
+	case protocol.TypeChat:
+		var chat protocol.Chat
+		if err := msg.Unmarshal(&chat); err != nil {
+			return
+		}
+		c.receiveChat(chat)
+
+	case protocol.TypeRunRequest:
+		var req protocol.RunRequest
+		if err := msg.Unmarshal(&req); err != nil {
+			return
+		}
+		go c.handleRunRequest(msg.From, req)
+
+	case protocol.TypeRunStart:
+		var start protocol.RunStart
+		if msg.Unmarshal(&start) == nil {
+			c.showRunStart(start)
+		}
+
+	case protocol.TypeRunOutput:
+		var out protocol.RunOutput
+		if msg.Unmarshal(&out) == nil {
+			c.showRunOutput(out)
+		}
+
+	case protocol.TypeRunExit:
+		var exit protocol.RunExit
+		if msg.Unmarshal(&exit) == nil {
+			c.showRunExit(exit)
+		}
+
+	case protocol.TypeCheckResult:
+		var result protocol.CheckResult
+		if msg.Unmarshal(&result) == nil {
+			c.showCheckResult(result)
+		}
+
+	case protocol.TypeTimer:
+		var t protocol.Timer
+		if msg.Unmarshal(&t) == nil {
+			c.applyTimer(t)
+		}
+
+	case protocol.TypeHint:
+		var hint protocol.Hint
+		if msg.Unmarshal(&hint) == nil {
+			c.receiveHint(msg.From, hint)
+		}
+
+	case protocol.TypeDriver:
+		var d protocol.Driver
+		if msg.Unmarshal(&d) == nil {
+			c.applyDriver(d)
+		}
+
+	case protocol.TypeLock:
+		var lock protocol.Lock
+		if msg.Unmarshal(&lock) == nil {
+			c.applyLock(lock, true)
+		}
+
+	case protocol.TypeProposal:
+		var file protocol.File
+		if msg.Unmarshal(&file) == nil {
+			c.receiveProposal(msg.From, file)
+		}
+
+	case protocol.TypeReview:
+		var review protocol.Review
+		if msg.Unmarshal(&review) == nil {
+			c.receiveReview(msg.From, review)
+		}
+
+	case protocol.TypeComment:
+		var comment protocol.Comment
+		if msg.Unmarshal(&comment) == nil {
+			c.receiveComment(comment, true)
+		}
+
+	case protocol.TypeRejected:
+		var r protocol.Rejected
+		if msg.Unmarshal(&r) == nil {
+			c.receiveRejected(r)
+		}
+
+	case protocol.TypeCursor:
+		var cursor protocol.Cursor
+		if err := msg.Unmarshal(&cursor); err != nil {
+			return
+		}
+		if cursor.File != filepath.Base(cursor.File) || cursor.File == "." || cursor.File == ".." || ignore.MatchString(cursor.File) {
+			return
+		}
+		cursor.Name = msg.From
+		c.cursors.Store(msg.From, cursor)
+		c.api.Notify("cursor.update", cursor)
+		c.observe(msg.From, cursor.File, cursor.Line)
+	}
+}
@@ -0,0 +1,176 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/diff"
+)
+
+// stage holds incoming changes back with --confirm. Once a file has been
+// applied, later changes to it sync as usual; trusted peers skip the stage.
+type stage struct {
+	mu      sync.Mutex
+	pending map[string]Staged
+	applied map[string]bool
+	trusted map[string]bool
+	notice  *time.Timer
+}
+
+// Staged is an incoming change held back for approval.
+type Staged struct {
+	From    string
+	File    string
+	Content []byte
+	// Exists is set when the change would overwrite a local file.
+	Exists bool
+}
+
+// Summary describes a staged change in a few words.
+func (s Staged) Summary() string {
+	if !s.Exists {
+		return fmt.Sprintf("+ %s (new file, %d lines) from %s", s.File, len(diff.Lines(s.Content)), s.From)
+	}
+	current, _ := os.ReadFile(s.File)
+	added, deleted := 0, 0
+	for _, op := range diff.Compute(diff.Lines(current), diff.Lines(s.Content)) {
+		switch op.Kind {
+		case diff.Insert:
+			added++
+		case diff.Delete:
+			deleted++
+		}
+	}
+	return fmt.Sprintf("~ %s (overwrites your file: +%d -%d) from %s", s.File, added, deleted, s.From)
+}
+
+// hold stages a change instead of applying it, if it has to wait.
+func (c *Client) hold(from, filename string, content []byte) bool {
+	if !c.opts.Confirm {
+		return false
+	}
+	c.stage.mu.Lock()
+	defer c.stage.mu.Unlock()
+	if c.stage.trusted[from] || c.stage.applied[filename] {
+		return false
+	}
+	current, err := os.ReadFile(filename)
+	exists := err == nil
+	if exists && bytes.Equal(current, content) {
+		return false
+	}
+	if c.stage.pending == nil {
+		c.stage.pending = make(map[string]Staged)
+	}
+	c.stage.pending[filename] = Staged{From: from, File: filename, Content: content, Exists: exists}
+
+	// one summary per burst of changes
+	if c.stage.notice != nil {
+		c.stage.notice.Stop()
+	}
+	c.stage.notice = time.AfterFunc(300*time.Millisecond, c.printStaged)
+	return true
+}
+
+func (c *Client) printStaged() {
+	staged := c.Staged()
+	if len(staged) == 0 {
+		return
+	}
+	fmt.Println("Waiting for your approval:")
+	from := map[string]bool{}
+	for _, s := range staged {
+		fmt.Printf("   %s\n", s.Summary())
+		from[s.From] = true
+	}
+	var names []string
+	for name := range from {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("   /staged <file> for the diff, /apply, /discard or /trust %s\n", strings.Join(names, "|"))
+}
+
+// Staged lists the changes waiting for approval.
+func (c *Client) Staged() []Staged {
+	c.stage.mu.Lock()
+	defer c.stage.mu.Unlock()
+	list := make([]Staged, 0, len(c.stage.pending))
+	for _, s := range c.stage.pending {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].File < list[j].File })
+	return list
+}
+
+// StagedDiff shows what applying the staged change to file would do.
+func (c *Client) StagedDiff(file string) (string, error) {
+	c.stage.mu.Lock()
+	s, ok := c.stage.pending[file]
+	c.stage.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("nothing staged for %s", file)
+	}
+	current, _ := os.ReadFile(file)
+	return diff.Unified(file+" (yours)", file+" ("+s.From+")", current, s.Content), nil
+}
+
+// Apply writes staged changes to disk, all of them if no files are given.
+func (c *Client) Apply(files ...string) {
+	for _, s := range c.take(files, true, func(s Staged) bool { return true }) {
+		c.applyFile(s.From, s.File, s.Content)
+	}
+}
+
+// Discard drops staged changes, all of them if no files are given. The
+// next change to a discarded file is staged again.
+func (c *Client) Discard(files ...string) {
+	for _, s := range c.take(files, false, func(s Staged) bool { return true }) {
+		fmt.Printf("discarded %s from %s\n", s.File, s.From)
+	}
+}
+
+// Trust applies everything staged from name and lets the rest of its
+// changes through.
+func (c *Client) Trust(name string) {
+	c.stage.mu.Lock()
+	if c.stage.trusted == nil {
+		c.stage.trusted = make(map[string]bool)
+	}
+	c.stage.trusted[name] = true
+	c.stage.mu.Unlock()
+	fmt.Printf("Trusting %s for the rest of the session\n", name)
+	for _, s := range c.take(nil, true, func(s Staged) bool { return s.From == name }) {
+		c.applyFile(s.From, s.File, s.Content)
+	}
+}
+
+// take removes the matching staged changes. Applied files sync freely from
+// then on.
+func (c *Client) take(files []string, apply bool, match func(Staged) bool) []Staged {
+	c.stage.mu.Lock()
+	defer c.stage.mu.Unlock()
+	if c.stage.applied == nil {
+		c.stage.applied = make(map[string]bool)
+	}
+	want := map[string]bool{}
+	for _, f := range files {
+		want[f] = true
+	}
+	var taken []Staged
+	for file, s := range c.stage.pending {
+		if len(files) > 0 && !want[file] || !match(s) {
+			continue
+		}
+		delete(c.stage.pending, file)
+		c.stage.applied[file] = apply
+		taken = append(taken, s)
+	}
+	sort.Slice(taken, func(i, j int) bool { return taken[i].File < taken[j].File })
+	return taken
+}
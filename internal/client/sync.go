@@ -3,7 +3,6 @@ package client
 import (
 	"context"
     "crypto/sha256"
-	"encoding/base64"
     "encoding/hex"
     "fmt"
     "log"
@@ -14,13 +13,21 @@ import (
     "sync"
 	"sync/atomic"
     "time"
+    "github.com/go-johnnyhe/waveland/internal/comments"
+    "github.com/go-johnnyhe/waveland/internal/config"
+    "github.com/go-johnnyhe/waveland/internal/diff"
+    "github.com/go-johnnyhe/waveland/internal/editorapi"
+    "github.com/go-johnnyhe/waveland/internal/history"
+    "github.com/go-johnnyhe/waveland/internal/merkle"
+    "github.com/go-johnnyhe/waveland/internal/protocol"
+    "github.com/go-johnnyhe/waveland/internal/record"
     "github.com/go-johnnyhe/waveland/internal/wsutil"
 
     "github.com/fsnotify/fsnotify"
     "github.com/gorilla/websocket"
 )
 
-var ignore = regexp.MustCompile(`(?i)(?:^|[\\/])(?:\.git|\.hg|\.svn|\.vscode|\.idea)(?:[\\/]|$)|(?:^|[\\/])\.s\.pgsql\.\d+$|\.ds_store$|\.sw[a-p0-9]$|\.swp$|\.swo$|~$|\.bak$|\.tmp$`)
+var ignore = regexp.MustCompile(`(?i)(?:^|[\\/])(?:\.git|\.waveland|\.hg|\.svn|\.vscode|\.idea)(?:[\\/]|$)|(?:^|[\\/])\.s\.pgsql\.\d+$|\.ds_store$|\.sw[a-p0-9]$|\.swp$|\.swo$|~$|\.bak$|\.tmp$`)
 
 type Client struct {
 	conn *wsutil.Peer
@@ -28,16 +35,100 @@ type Client struct {
 	timerMutex sync.Mutex
 	isWritingReceivedFile atomic.Bool
 	lastHash sync.Map
+	history *history.Store
+	opts Options
+	name atomic.Value
+	api *editorapi.Server
+	cursors sync.Map
+	follow followState
+	chat chatLog
+	check checker
+	private atomic.Value
+	timerState timerState
+	mob mobState
+	locks sync.Map
+	review reviewState
+	comments *comments.Store
+	stage stage
+	manifests chan protocol.Message
+	tree *merkle.Tree
+	blobs blobState
+	// kept holds the files reconcile kept to this side
+	kept sync.Map
 }
 
-func NewClient(conn *websocket.Conn) *Client {
-	return &Client {
+type Options struct {
+	// Name is how this participant is shown to everyone else.
+	Name string
+	// HostToken marks the host's own client, see server.HostToken.
+	HostToken string
+	// Recorder, if set, receives every change this client sends or applies.
+	Recorder *record.Writer
+	// EditorSocket is where to serve the editor API; empty disables it.
+	EditorSocket string
+	// Follow is the participant whose edits the local editor should track.
+	Follow string
+	// OpenCommand is run to open the followed file, with {file} and {line}
+	// replaced, e.g. "code -g {file}:{line}".
+	OpenCommand string
+	// RunAllow lists commands the host runs for peers without asking.
+	RunAllow []string
+	// OnChange is a command the host runs after every batch of changes,
+	// e.g. the tests; the result is shared with everyone.
+	OnChange string
+	// Private lists file patterns that are never sent, e.g. the
+	// interviewer's notes. The server can add more in its welcome.
+	Private []string
+	// Confirm holds back new files and first changes to existing local
+	// files until the user applies them, unless the sender is trusted.
+	Confirm bool
+	// Prefer settles files that differ from the host's when joining, see
+	// the Prefer constants. Empty skips the reconciliation.
+	Prefer string
+	// Approve asks the local user a yes/no question, e.g. whether a peer
+	// may run a command. Without it such requests are declined.
+	Approve func(question string) bool
+	// TerminalTokenFile is where to keep the token that lets 'waveland
+	// join --terminal' watch the shared shell under this client's name.
+	TerminalTokenFile string
+}
+
+func NewClient(conn *websocket.Conn, opts Options) *Client {
+	store, err := history.Open(history.DefaultDir)
+	if err != nil {
+		log.Println("file history disabled: ", err)
+	}
+	sidecar, err := comments.Open(comments.Path)
+	if err != nil {
+		log.Println("comments disabled: ", err)
+	}
+	c := &Client {
 		conn: wsutil.NewPeer(conn),
+		history: store,
+		opts: opts,
+		comments: sidecar,
+		manifests: make(chan protocol.Message, 1),
+		tree: merkle.New(),
 	}
+	c.follow.name = opts.Follow
+	c.private.Store(opts.Private)
+	return c
 }
 
 func (c *Client) Start(ctx context.Context) {
+	if err := c.send(protocol.TypeHello, protocol.Hello{Name: c.opts.Name, Token: c.opts.HostToken}); err != nil {
+		log.Println("error joining the session: ", err)
+	}
+	c.startEditorAPI(ctx)
 	go c.readLoop()
+	c.buildTree()
+	if c.opts.Prefer != "" && !c.isHost() {
+		go func() {
+			c.reconcile(ctx)
+			c.monitorFiles(ctx)
+		}()
+		return
+	}
 	go c.monitorFiles(ctx)
 }
 
@@ -67,76 +158,126 @@ func (c *Client) SendFile(filePath string) {
 	}
 
 	key := filepath.Base(filePath)
+	if c.isPrivate(key) {
+		return
+	}
+	if _, ok := c.kept.Load(key); ok {
+		return
+	}
 	newHash := fileHash(content)
-
-	if prevContent, ok := c.lastHash.Load(key); ok && prevContent.(string) == newHash {
+	c.tree.Set(key, newHash)
+	prevHash, seen := c.lastHash.Load(key)
+	if seen && prevHash.(string) == newHash {
 		log.Printf("Debug skip %s - hash unchanged", key)
 		return
 	}
+	if c.readOnly() || c.navigating() {
+		return
+	}
 
 	c.lastHash.Store(key, newHash)
-	encodedContent := base64.StdEncoding.EncodeToString(content)
-	message := fmt.Sprintf("%s|%s", key, encodedContent)
-
-	if err := c.conn.Write(websocket.TextMessage, []byte(message)); err != nil {
+	if err := c.send(protocol.TypeFile, c.outgoing(key, content, newHash)); err != nil {
 		log.Println("error writing the file: ", err)
 		return
 	}
 
 	fmt.Printf("-> %s\n", filepath.Base(filePath))
+	if seen && c.history != nil {
+		if previous, err := c.history.Get(prevHash.(string)); err == nil {
+			c.remapComments(key, previous, content)
+		}
+	}
+	c.recordVersion(key, content, c.Name())
+	c.scheduleCheck()
+}
+
+func (c *Client) recordVersion(name string, content []byte, author string) {
+	if c.history != nil {
+		if _, _, err := c.history.Record(name, content, author); err != nil {
+			log.Printf("error recording history for %s: %v\n", name, err)
+		}
+	}
+	if c.opts.Recorder != nil {
+		if err := c.opts.Recorder.Add(name, author, content); err != nil {
+			log.Printf("error writing session recording: %v\n", err)
+		}
+	}
 }
 
 func (c *Client) readLoop() {
 	for {
-		_, msg, err := c.conn.ReadMessage()
+		_, raw, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("Connection lost: %v", err)
 			}
 			return
 		}
-		if len(msg) > 10 * 1024 * 1024 {
-			log.Printf("message too large: %d bytes", len(msg))
+		if len(raw) > 15 * 1024 * 1024 {
+			log.Printf("message too large: %d bytes", len(raw))
 			continue
 		}
 
-		parts := strings.SplitN(string(msg), "|", 2)
-		if len(parts) != 2 {
-			log.Printf("Received invalid message format: %s\n", string(msg))
+		msg, err := protocol.Decode(raw)
+		if err != nil {
+			log.Printf("Received invalid message: %v\n", err)
 			continue
 		}
+		c.handleMessage(msg)
+	}
+}
 
-		// check if file path is clean
-		filename := filepath.Base(parts[0])
+func (c *Client) receiveFile(from string, file protocol.File) {
+	// check if file path is clean
+	filename := filepath.Base(file.Name)
 
-		if ignore.MatchString(filename) {
-			continue
-		}
-
-		cleanPath := filepath.Clean(filename)
-		if cleanPath != filename || strings.Contains(filename, "..") || strings.HasPrefix(filename, "/") {
-			log.Printf("invalid name: %s\n", filename)
-			continue
-		}
-		decodedContent, err := base64.StdEncoding.DecodeString(parts[1])
-		if err != nil {
-			log.Printf("error decoding content for %s: %v\n", filename, err)
-			continue
-		}
+	if ignore.MatchString(filename) {
+		return
+	}
 
+	if c.isPrivate(filename) {
+		log.Printf("refusing private file %s from %s\n", filename, from)
+		return
+	}
 
-		c.isWritingReceivedFile.Store(true)
+	cleanPath := filepath.Clean(filename)
+	if cleanPath != filename || strings.Contains(filename, "..") || strings.HasPrefix(filename, "/") {
+		log.Printf("invalid name: %s\n", filename)
+		return
+	}
 
-		func() {
-			defer c.isWritingReceivedFile.Store(false)
-				if err = os.WriteFile(filename, decodedContent, 0644); err != nil {
-					log.Printf("error writing this file: %s: %v\n", filename, err)
-				} else{
-					fmt.Printf("<- %s\n", filename)
-				}
-		}()
-		c.lastHash.Store(filename, fileHash(decodedContent))
+	if c.hold(from, filename, file.Content) {
+		return
 	}
+	c.applyFile(from, filename, file.Content)
+}
+
+// applyFile writes a received change to disk.
+func (c *Client) applyFile(from, filename string, content []byte) {
+	file := protocol.File{Name: filename, Content: content}
+	previous, _ := os.ReadFile(filename)
+	c.isWritingReceivedFile.Store(true)
+
+	func() {
+		defer c.isWritingReceivedFile.Store(false)
+			if err := os.WriteFile(filename, file.Content, 0644); err != nil {
+				log.Printf("error writing this file: %s: %v\n", filename, err)
+			} else{
+				fmt.Printf("<- %s: %s\n", from, filename)
+				c.remapComments(filename, previous, file.Content)
+				c.recordVersion(filename, file.Content, from)
+				c.scheduleCheck()
+			}
+	}()
+	c.lastHash.Store(filename, fileHash(file.Content))
+	c.tree.Set(filename, fileHash(file.Content))
+	c.kept.Delete(filename)
+	c.observe(from, filename, diff.FirstChangedLine(previous, file.Content))
+}
+
+func (c *Client) isPrivate(name string) bool {
+	patterns, _ := c.private.Load().([]string)
+	return config.Match(patterns, name)
 }
 
 func (c *Client) monitorFiles(ctx context.Context) {
@@ -156,10 +297,9 @@ func (c *Client) monitorFiles(ctx context.Context) {
 	if err := watcher.Add("."); err != nil {
 		// Don't confuse users with partial functionality
 		fmt.Println("\n❌ Cannot watch this directory (filesystem issue)")
-		fmt.Println("\n✅ Quick fix - run these 2 commands:")
-		fmt.Println("   $ mkdir -p /tmp/waveland && cd /tmp/waveland")
-		fmt.Println("   $ waveland join <session-url>")
-		fmt.Println("\nThis will start your session in a clean directory.")
+		fmt.Println("\n✅ Quick fix - join into a clean temporary directory:")
+		fmt.Println("   $ waveland join --ephemeral <session-url>")
+		fmt.Println("\nor pick one yourself with --dir <path>.")
 		os.Exit(1)
 	}
 
@@ -201,6 +341,9 @@ func (c *Client) processFileEvents(ctx context.Context,watcher *fsnotify.Watcher
 			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Chmod) != 0 {
 				c.handleFileEvent(event)
 			}
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				c.track(filepath.Base(event.Name))
+			}
 		case err, ok := <- watcher.Errors:
 			if !ok {
 				return
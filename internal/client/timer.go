@@ -0,0 +1,124 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// timerState mirrors the host's session timer and prints remaining-time
+// notices locally, so they don't depend on the host's messages arriving.
+type timerState struct {
+	mu      sync.Mutex
+	current *protocol.Timer
+	notices []*time.Timer
+	// warned is set once a read-only notice was printed for this freeze.
+	warned bool
+}
+
+// noticesAt are the remaining times worth a notice, if the timer is longer.
+var noticesAt = []time.Duration{15 * time.Minute, 10 * time.Minute, 5 * time.Minute, time.Minute}
+
+func (c *Client) applyTimer(t protocol.Timer) {
+	c.timerState.mu.Lock()
+	prev := c.timerState.current
+	c.timerState.current = &t
+	if prev == nil {
+		prev = &protocol.Timer{}
+	}
+	restarted := !t.Started.Equal(prev.Started)
+	if restarted {
+		for _, n := range c.timerState.notices {
+			n.Stop()
+		}
+		c.timerState.notices = nil
+		if !t.Started.IsZero() {
+			c.scheduleNotices(t)
+		}
+	}
+	if t.Frozen != prev.Frozen {
+		c.timerState.warned = false
+	}
+	c.timerState.mu.Unlock()
+
+	if restarted && !t.Started.IsZero() {
+		fmt.Printf("⏱  %s left\n", formatRemaining(t.Remaining()))
+	}
+	if t.Phase != prev.Phase && t.Phase != "" {
+		fmt.Printf("== phase: %s ==\n", t.Phase)
+	}
+	if t.Frozen != prev.Frozen {
+		switch {
+		case t.Frozen && c.isHost():
+			fmt.Println("Files are frozen, only you can change them now")
+		case t.Frozen:
+			fmt.Println("Files are frozen: your changes are no longer shared")
+		default:
+			fmt.Println("Files are open for editing again")
+		}
+	}
+	c.api.Notify("timer.update", t)
+}
+
+// scheduleNotices must be called with the timer lock held.
+func (c *Client) scheduleNotices(t protocol.Timer) {
+	remaining := t.Remaining()
+	at := append([]time.Duration{}, noticesAt...)
+	if half := t.Duration / 2; half > noticesAt[0] {
+		at = append([]time.Duration{half}, at...)
+	}
+	for _, left := range at {
+		if left >= remaining {
+			continue
+		}
+		left := left
+		c.timerState.notices = append(c.timerState.notices, time.AfterFunc(remaining-left, func() {
+			fmt.Printf("⏱  %s left\n", formatRemaining(left))
+		}))
+	}
+	c.timerState.notices = append(c.timerState.notices, time.AfterFunc(remaining, func() {
+		fmt.Println("⏱  time is up")
+	}))
+}
+
+// Timer returns the session timer, or nil if the host didn't set one.
+func (c *Client) Timer() *protocol.Timer {
+	c.timerState.mu.Lock()
+	defer c.timerState.mu.Unlock()
+	if c.timerState.current == nil {
+		return nil
+	}
+	t := *c.timerState.current
+	return &t
+}
+
+// readOnly reports whether local changes must not be sent, telling the user
+// the first time.
+func (c *Client) readOnly() bool {
+	if c.isHost() {
+		return false
+	}
+	c.timerState.mu.Lock()
+	defer c.timerState.mu.Unlock()
+	if c.timerState.current == nil || !c.timerState.current.Frozen {
+		return false
+	}
+	if !c.timerState.warned {
+		c.timerState.warned = true
+		fmt.Println("Files are frozen, this change was not shared")
+	}
+	return true
+}
+
+func formatRemaining(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Round(time.Second).Seconds()))
+	}
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes >= 60 {
+		return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
@@ -0,0 +1,152 @@
+package comments
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/go-johnnyhe/waveland/internal/diff"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// Path is the sidecar comments are kept in, next to the code but never
+// synced as a file.
+var Path = filepath.Join(".waveland", "comments.json")
+
+// Store holds the comments of a session, saved to its path after every
+// change. An empty path keeps them in memory only.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	list map[string]protocol.Comment
+}
+
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, list: make(map[string]protocol.Comment)}
+	if path == "" {
+		return s, nil
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range loaded {
+		s.list[c.ID] = c
+	}
+	return s, nil
+}
+
+// Load reads a sidecar. A missing file has no comments.
+func Load(path string) ([]protocol.Comment, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []protocol.Comment
+	err = json.Unmarshal(data, &list)
+	return list, err
+}
+
+// Put adds or updates a comment; a Deleted one is removed.
+func (s *Store) Put(c protocol.Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.Deleted {
+		delete(s.list, c.ID)
+	} else {
+		s.list[c.ID] = c
+	}
+	return s.save()
+}
+
+func (s *Store) Get(id string) (protocol.Comment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.list[id]
+	return c, ok
+}
+
+// List returns the comments on file, or on every file if it is empty,
+// ordered by file and line.
+func (s *Store) List(file string) []protocol.Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []protocol.Comment{}
+	for _, c := range s.list {
+		if file == "" || c.File == file {
+			list = append(list, c)
+		}
+	}
+	Sort(list)
+	return list
+}
+
+// Remap moves the comments on file from old to new content and returns the
+// ones that moved.
+func (s *Store) Remap(file string, old, new []byte) ([]protocol.Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ops []diff.Op
+	var moved []protocol.Comment
+	for id, c := range s.list {
+		if c.File != file || c.Outdated {
+			continue
+		}
+		if ops == nil {
+			ops = diff.Compute(diff.Lines(old), diff.Lines(new))
+		}
+		line, startKept := diff.MapLine(ops, c.Line)
+		end, endKept := diff.MapLine(ops, c.EndLine)
+		if end < line {
+			end = line
+		}
+		outdated := !startKept && !endKept
+		if line == c.Line && end == c.EndLine && !outdated {
+			continue
+		}
+		c.Line, c.EndLine, c.Outdated = line, end, outdated
+		s.list[id] = c
+		moved = append(moved, c)
+	}
+	if len(moved) == 0 {
+		return nil, nil
+	}
+	return moved, s.save()
+}
+
+// save must be called with the lock held.
+func (s *Store) save() error {
+	if s.path == "" {
+		return nil
+	}
+	list := make([]protocol.Comment, 0, len(s.list))
+	for _, c := range s.list {
+		list = append(list, c)
+	}
+	Sort(list)
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+func Sort(list []protocol.Comment) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].File != list[j].File {
+			return list[i].File < list[j].File
+		}
+		if list[i].Line != list[j].Line {
+			return list[i].Line < list[j].Line
+		}
+		return list[i].Time.Before(list[j].Time)
+	})
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Path is the session configuration file, relative to the shared directory.
+var Path = filepath.Join(".waveland", "config.json")
+
+// Config holds the host's settings for a session, e.g.
+//
+//	{"run": {"allow": ["go test ./...", "make test"]}, "interview": {"private": ["notes*.md"]}}
+type Config struct {
+	Run       Run       `json:"run"`
+	Interview Interview `json:"interview"`
+}
+
+type Run struct {
+	// Allow lists commands peers may run without asking the host, see
+	// Allowed.
+	Allow []string `json:"allow"`
+}
+
+type Interview struct {
+	// Private lists files that are never shared with the candidate. Only
+	// the directory's own files are shared, so patterns match file names,
+	// not paths.
+	Private []string `json:"private"`
+}
+
+// Load reads the configuration file. A missing file is not an error.
+func Load() (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(Path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid %s: %v", Path, err)
+	}
+	return cfg, nil
+}
+
+// Allowed reports whether the command args matches any of the patterns. A
+// pattern is compared argument by argument: a "*" stands for exactly one
+// argument that isn't a flag, and every other word must match exactly. So
+// "go test *" allows "go test ./pkg" but not "go test -exec sh ./pkg".
+func Allowed(patterns []string, args []string) bool {
+	for _, pattern := range patterns {
+		words := strings.Fields(pattern)
+		if len(words) != len(args) {
+			continue
+		}
+		ok := true
+		for i, word := range words {
+			if word == "*" {
+				ok = ok && args[i] != "" && !strings.HasPrefix(args[i], "-")
+			} else {
+				ok = ok && word == args[i]
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether name matches any of the patterns, where a * matches
+// anything.
+func Match(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+		if ok, _ := regexp.MatchString(expr, name); ok {
+			return true
+		}
+	}
+	return false
+}
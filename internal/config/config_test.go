@@ -0,0 +1,52 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	patterns := []string{"go test *", "go test ./...", "make test"}
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"go test ./...", true},
+		{"go test ./pkg", true},
+		{"make test", true},
+		{"make", false},
+		{"make test clean", false},
+		{"go test -exec sh ./pkg", false},
+		{"go test -toolexec=x", false},
+		{"go test ./a ./b", false},
+		{"go vet ./...", false},
+	}
+	for _, tt := range tests {
+		if got := Allowed(patterns, strings.Fields(tt.command)); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+	if Allowed(patterns, []string{"go", "test", "./a; rm -rf /"}) != true {
+		t.Errorf("an argument is matched as a whole")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	patterns := []string{"NOTES.md", "notes*.md", "*.secret"}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"NOTES.md", true},
+		{"notes-day1.md", true},
+		{"notes.txt", false},
+		{"key.secret", true},
+		{"solution.py", false},
+		{"NOTES.md.bak", false},
+	}
+	for _, tt := range tests {
+		if got := Match(patterns, tt.name); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Console reads commands typed into the session terminal. Commands start with
+// a slash, e.g. "/who"; any other line goes to the text handler (chat).
+type Console struct {
+	mu       sync.Mutex
+	commands map[string]command
+	text     func(line string)
+	raw      func(chunk []byte) bool
+	prompts  []prompt
+	in       io.Reader
+}
+
+type prompt struct {
+	question string
+	answer   func(string)
+}
+
+type command struct {
+	usage string
+	help  string
+	run   func(args []string)
+}
+
+func New() *Console {
+	c := &Console{commands: make(map[string]command), in: os.Stdin}
+	c.Handle("help", "", "list the available commands", func([]string) {
+		c.printHelp()
+	})
+	return c
+}
+
+// Handle registers a command. usage describes its arguments, if any.
+func (c *Console) Handle(name, usage, help string, run func(args []string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands[name] = command{usage: usage, help: help, run: run}
+}
+
+// HandleText sets what happens to lines that are not commands.
+func (c *Console) HandleText(fn func(line string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.text = fn
+}
+
+// Raw hands all input to fn, unsplit, until fn returns false. It is used to
+// attach the terminal to something else, like a shared shell.
+func (c *Console) Raw(fn func(chunk []byte) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.raw = fn
+}
+
+// Ask prints question and hands the next line typed to answer instead of
+// treating it as a command or chat. Questions asked while another one is
+// still open wait their turn.
+func (c *Console) Ask(question string, answer func(string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prompts = append(c.prompts, prompt{question: question, answer: answer})
+	if len(c.prompts) == 1 {
+		fmt.Print(question)
+	}
+}
+
+// Confirm asks a yes/no question and waits for the answer.
+func (c *Console) Confirm(question string) bool {
+	answer := make(chan bool, 1)
+	c.Ask(question+" [y/N] ", func(line string) {
+		answer <- strings.HasPrefix(strings.ToLower(line), "y")
+	})
+	return <-answer
+}
+
+// Run reads input until stdin closes or ctx is done.
+func (c *Console) Run(ctx context.Context) {
+	chunks := make(chan []byte)
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 4096)
+		for {
+			n, err := c.in.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- append([]byte(nil), buf[:n]...):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var pending []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk, ok := <-chunks:
+			if !ok {
+				return
+			}
+			c.mu.Lock()
+			raw := c.raw
+			c.mu.Unlock()
+			if raw != nil {
+				if !raw(chunk) {
+					c.Raw(nil)
+				}
+				continue
+			}
+
+			pending = append(pending, chunk...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				line := strings.TrimSpace(string(pending[:i]))
+				pending = pending[i+1:]
+				if !c.answer(line) {
+					c.dispatch(line)
+				}
+			}
+		}
+	}
+}
+
+// answer passes line to the oldest open question, if there is one.
+func (c *Console) answer(line string) bool {
+	c.mu.Lock()
+	if len(c.prompts) == 0 {
+		c.mu.Unlock()
+		return false
+	}
+	p := c.prompts[0]
+	c.prompts = c.prompts[1:]
+	if len(c.prompts) > 0 {
+		fmt.Print(c.prompts[0].question)
+	}
+	c.mu.Unlock()
+
+	p.answer(line)
+	return true
+}
+
+func (c *Console) dispatch(line string) {
+	if line == "" {
+		return
+	}
+	if !strings.HasPrefix(line, "/") {
+		c.mu.Lock()
+		text := c.text
+		c.mu.Unlock()
+		if text == nil {
+			fmt.Println("Commands start with /, type /help to list them")
+			return
+		}
+		text(line)
+		return
+	}
+
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 {
+		return
+	}
+	c.mu.Lock()
+	cmd, ok := c.commands[fields[0]]
+	c.mu.Unlock()
+	if !ok {
+		fmt.Printf("Unknown command /%s, type /help to list them\n", fields[0])
+		return
+	}
+	cmd.run(fields[1:])
+}
+
+func (c *Console) printHelp() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.commands))
+	for name := range c.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := c.commands[name]
+		fmt.Printf("  %-24s %s\n", strings.TrimSpace("/"+name+" "+cmd.usage), cmd.help)
+	}
+}
@@ -0,0 +1,305 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is one line of an edit script. A and B are the line indexes in the old
+// and new text; A is meaningless for inserts and B for deletes.
+type Op struct {
+	Kind OpKind
+	A, B int
+	Text string
+}
+
+type Hunk struct {
+	AStart, ALen int
+	BStart, BLen int
+	Ops          []Op
+}
+
+// Lines splits content into lines, keeping the trailing newline on each one
+// so that joining them gives back the exact input.
+func Lines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// maxCost bounds the search for a shortest edit script. Beyond it a changed
+// stretch is reported as deleted and inserted whole, so even two large,
+// completely different files diff quickly.
+const maxCost = 2000
+
+// Compute returns the shortest edit script turning a into b, using the
+// linear space variant of Myers' algorithm.
+func Compute(a, b []string) []Op {
+	var ops []Op
+	compute(a, b, 0, 0, &ops)
+	return ops
+}
+
+// compute appends the edit script for a and b, which start at line aOff
+// and bOff of the whole texts.
+func compute(a, b []string, aOff, bOff int, ops *[]Op) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		*ops = append(*ops, Op{Kind: Equal, A: aOff + prefix, B: bOff + prefix, Text: a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	aOff, bOff = aOff+prefix, bOff+prefix
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	n, m := len(a)-suffix, len(b)-suffix
+
+	x, y, u, v, ok := 0, 0, 0, 0, false
+	if n > 0 && m > 0 {
+		x, y, u, v, ok = middleSnake(a[:n], b[:m])
+	}
+	if ok {
+		compute(a[:x], b[:y], aOff, bOff, ops)
+		for i := x; i < u; i++ {
+			*ops = append(*ops, Op{Kind: Equal, A: aOff + i, B: bOff + y + i - x, Text: a[i]})
+		}
+		compute(a[u:n], b[v:m], aOff+u, bOff+v, ops)
+	} else {
+		for i := 0; i < n; i++ {
+			*ops = append(*ops, Op{Kind: Delete, A: aOff + i, B: bOff, Text: a[i]})
+		}
+		for i := 0; i < m; i++ {
+			*ops = append(*ops, Op{Kind: Insert, A: aOff + n, B: bOff + i, Text: b[i]})
+		}
+	}
+
+	for i := 0; i < suffix; i++ {
+		*ops = append(*ops, Op{Kind: Equal, A: aOff + n + i, B: bOff + m + i, Text: a[n+i]})
+	}
+}
+
+// middleSnake finds the middle of a shortest edit script for a and b,
+// which differ in their first and last lines: a stretch of equal lines from
+// (x, y) to (u, v) that splits it into two halves. ok is false when the
+// edit script is longer than 2*maxCost, or has no such stretch.
+func middleSnake(a, b []string) (x, y, u, v int, ok bool) {
+	n, m := len(a), len(b)
+	limit := min((n+m+1)/2, maxCost)
+	offset := limit + 1
+	// forward[k] is the furthest x on diagonal k = x-y from the start;
+	// backward[k] the furthest distance from the end on diagonal k, counted
+	// on the reversed texts
+	forward := make([]int, 2*limit+3)
+	backward := make([]int, 2*limit+3)
+	delta := n - m
+	odd := delta%2 != 0
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if r := delta - k; odd && r >= -(d-1) && r <= d-1 && x+backward[offset+r] >= n {
+				return x0, y0, x, y, true
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if f := delta - k; !odd && f >= -d && f <= d && x+forward[offset+f] >= n {
+				return n - x, m - y, n - x0, m - y0, true
+			}
+		}
+	}
+	return 0, 0, 0, 0, false
+}
+
+// Hunks groups an edit script into hunks with the given lines of context.
+func Hunks(ops []Op, context int) []Hunk {
+	aPos := make([]int, len(ops)+1)
+	bPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if op.Kind != Insert {
+			aPos[i+1]++
+		}
+		if op.Kind != Delete {
+			bPos[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	floor := 0
+	for i := 0; i < len(ops); i++ {
+		if ops[i].Kind == Equal {
+			continue
+		}
+		start := i - context
+		if start < floor {
+			start = floor
+		}
+		last := i
+		for j := i + 1; j < len(ops) && j-last <= 2*context+1; j++ {
+			if ops[j].Kind != Equal {
+				last = j
+			}
+		}
+		end := last + context + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		h := Hunk{AStart: aPos[start], BStart: bPos[start], Ops: ops[start:end]}
+		h.ALen = aPos[end] - aPos[start]
+		h.BLen = bPos[end] - bPos[start]
+		hunks = append(hunks, h)
+
+		floor = end
+		i = end - 1
+	}
+	return hunks
+}
+
+func (h Hunk) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.AStart, h.ALen), hunkRange(h.BStart, h.BLen))
+	for _, op := range h.Ops {
+		switch op.Kind {
+		case Equal:
+			sb.WriteString(" ")
+		case Delete:
+			sb.WriteString("-")
+		case Insert:
+			sb.WriteString("+")
+		}
+		sb.WriteString(op.Text)
+		if !strings.HasSuffix(op.Text, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+	return sb.String()
+}
+
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// Unified renders a unified diff between two versions of a file, or "" when
+// they are identical.
+func Unified(aName, bName string, a, b []byte) string {
+	hunks := Hunks(Compute(Lines(a), Lines(b)), 3)
+	if len(hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for _, h := range hunks {
+		sb.WriteString(h.String())
+	}
+	return sb.String()
+}
+
+// FirstChangedLine returns the 1-based line where b first differs from a,
+// or 0 when they are identical.
+func FirstChangedLine(a, b []byte) int {
+	la, lb := Lines(a), Lines(b)
+	for i := range lb {
+		if i >= len(la) || la[i] != lb[i] {
+			return i + 1
+		}
+	}
+	if len(la) > len(lb) {
+		// only deletions at the end; point at the new last line
+		return max(len(lb), 1)
+	}
+	return 0
+}
+
+// Apply rebuilds a new version of a from the hunks of its diff, taking only
+// the hunks accept returns true for and keeping a's lines elsewhere.
+func Apply(a []string, hunks []Hunk, accept func(i int) bool) []byte {
+	var sb strings.Builder
+	pos := 0
+	for i, h := range hunks {
+		if !accept(i) {
+			continue
+		}
+		for ; pos < h.AStart; pos++ {
+			sb.WriteString(a[pos])
+		}
+		for _, op := range h.Ops {
+			if op.Kind != Delete {
+				sb.WriteString(op.Text)
+			}
+		}
+		pos = h.AStart + h.ALen
+	}
+	for ; pos < len(a); pos++ {
+		sb.WriteString(a[pos])
+	}
+	return []byte(sb.String())
+}
+
+// MapLine follows a 1-based line of a through the edit script to its line in
+// b. A deleted line maps to where it used to be, and ok is false.
+func MapLine(ops []Op, line int) (int, bool) {
+	b := 0
+	for _, op := range ops {
+		switch op.Kind {
+		case Equal:
+			if op.A == line-1 {
+				return op.B + 1, true
+			}
+			b = op.B + 1
+		case Insert:
+			b = op.B + 1
+		case Delete:
+			if op.A == line-1 {
+				return b + 1, false
+			}
+		}
+	}
+	return b + 1, false
+}
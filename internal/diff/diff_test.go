@@ -0,0 +1,113 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func split(s string) []string {
+	return Lines([]byte(s))
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		edits int
+	}{
+		{"identical", "a\nb\nc\n", "a\nb\nc\n", 0},
+		{"empty", "", "", 0},
+		{"from empty", "", "a\nb\n", 2},
+		{"to empty", "a\nb\n", "", 2},
+		{"insert", "a\nc\n", "a\nb\nc\n", 1},
+		{"delete", "a\nb\nc\n", "a\nc\n", 1},
+		{"replace", "a\nb\nc\n", "a\nx\nc\n", 2},
+		{"move", "a\nb\nc\nd\n", "b\nc\nd\na\n", 2},
+		{"interleaved", "a\nb\nc\nd\ne\n", "x\nb\ny\nd\nz\n", 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := Compute(split(tt.a), split(tt.b))
+			var a, b strings.Builder
+			edits := 0
+			for _, op := range ops {
+				if op.Kind != Insert {
+					a.WriteString(op.Text)
+				}
+				if op.Kind != Delete {
+					b.WriteString(op.Text)
+				}
+				if op.Kind != Equal {
+					edits++
+				}
+			}
+			if a.String() != tt.a || b.String() != tt.b {
+				t.Errorf("script gives %q -> %q, want %q -> %q", a.String(), b.String(), tt.a, tt.b)
+			}
+			if edits != tt.edits {
+				t.Errorf("got %d edits, want %d", edits, tt.edits)
+			}
+		})
+	}
+}
+
+func TestComputeLarge(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20000; i++ {
+		a = append(a, "old "+string(rune('a'+i%26))+"\n")
+		b = append(b, "new "+string(rune('a'+i%26))+"\n")
+	}
+	ops := Compute(a, b)
+	if len(ops) != len(a)+len(b) {
+		t.Fatalf("got %d ops, want %d", len(ops), len(a)+len(b))
+	}
+}
+
+func TestApply(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\nELEVEN\n12\n"
+	hunks := Hunks(Compute(split(a), split(b)), 1)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	tests := []struct {
+		name   string
+		accept map[int]bool
+		want   string
+	}{
+		{"all", map[int]bool{0: true, 1: true}, b},
+		{"none", map[int]bool{}, a},
+		{"first", map[int]bool{0: true}, "1\nTWO\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"},
+		{"second", map[int]bool{1: true}, "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\nELEVEN\n12\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(split(a), hunks, func(i int) bool { return tt.accept[i] })
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapLine(t *testing.T) {
+	a := "a\nb\nc\nd\n"
+	b := "new\na\nc\nd\nmore\n"
+	ops := Compute(split(a), split(b))
+	tests := []struct {
+		line int
+		want int
+		ok   bool
+	}{
+		{1, 2, true},  // shifted down by the insert above it
+		{2, 3, false}, // deleted, lands where it used to be
+		{3, 3, true},
+		{4, 4, true},
+	}
+	for _, tt := range tests {
+		got, ok := MapLine(ops, tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("MapLine(%d) = %d, %v, want %d, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
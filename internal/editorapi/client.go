@@ -0,0 +1,121 @@
+package editorapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Client talks to a running session's editor API, for commands like
+// 'waveland run' that act on the session from another process.
+type Client struct {
+	conn    net.Conn
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[int64]chan reply
+	notes   chan Notification
+}
+
+type Notification struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type reply struct {
+	ID     *int64          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("no waveland session is running in this directory")
+	}
+	c := &Client{
+		conn:    conn,
+		pending: make(map[int64]chan reply),
+		notes:   make(chan Notification, 256),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *Client) readLoop() {
+	defer func() {
+		close(c.notes)
+		c.mu.Lock()
+		for id, ch := range c.pending {
+			close(ch)
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}()
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r reply
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if r.ID == nil {
+			c.notes <- Notification{Method: r.Method, Params: r.Params}
+			continue
+		}
+		c.mu.Lock()
+		ch := c.pending[*r.ID]
+		delete(c.pending, *r.ID)
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- r
+		}
+	}
+}
+
+// Call invokes method and decodes its result into result, if non-nil.
+func (c *Client) Call(method string, params, result any) error {
+	id := c.nextID.Add(1)
+	ch := make(chan reply, 1)
+	c.mu.Lock()
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	p, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	out, err := json.Marshal(request{JSONRPC: "2.0", ID: json.RawMessage(fmt.Sprint(id)), Method: method, Params: p})
+	if err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(append(out, '\n')); err != nil {
+		return err
+	}
+
+	r, ok := <-ch
+	if !ok {
+		return fmt.Errorf("session closed")
+	}
+	if r.Error != nil {
+		return r.Error
+	}
+	if result != nil && len(r.Result) > 0 {
+		return json.Unmarshal(r.Result, result)
+	}
+	return nil
+}
+
+// Notifications delivers everything the session pushes to editors. The
+// channel is closed when the session goes away.
+func (c *Client) Notifications() <-chan Notification {
+	return c.notes
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
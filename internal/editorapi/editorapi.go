@@ -0,0 +1,200 @@
+package editorapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SocketPath is where a session listens for editor plugins, relative to the
+// shared directory.
+var SocketPath = filepath.Join(".waveland", "editor.sock")
+
+// Handler answers one JSON-RPC method. The returned value becomes the result.
+type Handler func(params json.RawMessage) (any, error)
+
+// Server speaks newline-delimited JSON-RPC 2.0 over a Unix socket. Besides
+// answering requests it pushes notifications to every connected editor.
+type Server struct {
+	ln       net.Listener
+	path     string
+	mu       sync.Mutex
+	handlers map[string]Handler
+	conns    map[*conn]bool
+}
+
+type conn struct {
+	net.Conn
+	mu sync.Mutex
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *Error          `json:"error"`
+}
+
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+const (
+	codeParse          = -32700
+	codeMethodNotFound = -32601
+	codeServer         = -32000
+)
+
+func Listen(path string) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	// a socket left behind by a crashed session would make Listen fail
+	if _, err := os.Stat(path); err == nil {
+		if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("another session is already using %s", path)
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		ln:       ln,
+		path:     path,
+		handlers: make(map[string]Handler),
+		conns:    make(map[*conn]bool),
+	}, nil
+}
+
+func (s *Server) Handle(method string, h Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[method] = h
+}
+
+// Serve accepts editor connections until ctx is done, then removes the socket.
+func (s *Server) Serve(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		s.ln.Close()
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+		os.Remove(s.path)
+	}()
+
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{Conn: nc}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		go s.serveConn(c)
+	}
+}
+
+func (s *Server) serveConn(c *conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	scanner := bufio.NewScanner(c)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var req request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			c.write(errorResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: codeParse, Message: err.Error()}})
+			continue
+		}
+
+		s.mu.Lock()
+		h, ok := s.handlers[req.Method]
+		s.mu.Unlock()
+
+		var reply any
+		if !ok {
+			reply = errorResponse{JSONRPC: "2.0", ID: req.ID, Error: &Error{Code: codeMethodNotFound, Message: "unknown method " + req.Method}}
+		} else if result, err := h(req.Params); err != nil {
+			reply = errorResponse{JSONRPC: "2.0", ID: req.ID, Error: &Error{Code: codeServer, Message: err.Error()}}
+		} else {
+			reply = response{JSONRPC: "2.0", ID: req.ID, Result: result}
+		}
+		// requests without an id are notifications and get no reply
+		if req.ID != nil {
+			c.write(reply)
+		}
+	}
+}
+
+func (c *conn) write(v any) error {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_, err = c.Write(append(out, '\n'))
+	return err
+}
+
+// Notify pushes a notification to every connected editor. It is a no-op on a
+// nil Server so callers don't have to care whether the API is enabled.
+func (s *Server) Notify(method string, params any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	conns := make([]*conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range conns {
+		if err := c.write(notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+			log.Printf("error notifying editor: %v\n", err)
+		}
+	}
+}
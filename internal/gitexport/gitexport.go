@@ -0,0 +1,204 @@
+package gitexport
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/history"
+)
+
+// DefaultQuiet is the pause in editing that ends one commit and starts the next.
+const DefaultQuiet = 2 * time.Minute
+
+type Options struct {
+	// Quiet is the gap between changes that separates two commits.
+	Quiet time.Duration
+	// Since skips versions recorded before this time when non-zero.
+	Since time.Time
+}
+
+type change struct {
+	file string
+	history.Version
+}
+
+// Export writes the recorded history into a new git repository at dir, one
+// commit per burst of editing, and returns the number of commits made.
+func Export(store *history.Store, dir string, opts Options) (int, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return 0, fmt.Errorf("git is not installed")
+	}
+	if opts.Quiet <= 0 {
+		opts.Quiet = DefaultQuiet
+	}
+
+	changes, err := collect(store, opts.Since)
+	if err != nil {
+		return 0, err
+	}
+	if len(changes) == 0 {
+		return 0, fmt.Errorf("no recorded changes to export")
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return 0, fmt.Errorf("%s already exists and is not empty", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+	if err := git(dir, nil, "init", "--quiet"); err != nil {
+		return 0, err
+	}
+
+	commits := 0
+	for _, group := range split(changes, opts.Quiet) {
+		for _, ch := range group {
+			content, err := store.Get(ch.Hash)
+			if err != nil {
+				return commits, fmt.Errorf("missing content for %s@%d: %v", ch.file, ch.N, err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, ch.file), content, 0644); err != nil {
+				return commits, err
+			}
+		}
+		if err := git(dir, nil, "add", "--all"); err != nil {
+			return commits, err
+		}
+		// a burst that ends where it started leaves nothing to commit
+		if !hasStaged(dir) {
+			continue
+		}
+
+		author, coauthors := authors(group)
+		when := group[len(group)-1].Time.Format(time.RFC3339)
+		env := []string{
+			"GIT_AUTHOR_NAME=" + author,
+			"GIT_AUTHOR_EMAIL=" + email(author),
+			"GIT_AUTHOR_DATE=" + when,
+			"GIT_COMMITTER_NAME=waveland",
+			"GIT_COMMITTER_EMAIL=waveland@users.noreply.waveland",
+			"GIT_COMMITTER_DATE=" + when,
+		}
+		if err := git(dir, env, "commit", "--quiet", "--no-verify", "-m", message(group, coauthors)); err != nil {
+			return commits, err
+		}
+		commits++
+	}
+	return commits, nil
+}
+
+func collect(store *history.Store, since time.Time) ([]change, error) {
+	files, err := store.Files()
+	if err != nil {
+		return nil, err
+	}
+	var changes []change
+	for _, file := range files {
+		versions, err := store.Versions(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			if v.Time.Before(since) {
+				continue
+			}
+			changes = append(changes, change{file: file, Version: v})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Time.Before(changes[j].Time)
+	})
+	return changes, nil
+}
+
+// split cuts the changes into bursts separated by at least quiet.
+func split(changes []change, quiet time.Duration) [][]change {
+	var groups [][]change
+	start := 0
+	for i := 1; i <= len(changes); i++ {
+		if i == len(changes) || changes[i].Time.Sub(changes[i-1].Time) >= quiet {
+			groups = append(groups, changes[start:i])
+			start = i
+		}
+	}
+	return groups
+}
+
+// authors picks whoever made the most changes in a burst as the commit
+// author; everyone else who contributed becomes a co-author.
+func authors(group []change) (string, []string) {
+	counts := make(map[string]int)
+	var order []string
+	for _, ch := range group {
+		if counts[ch.Author] == 0 {
+			order = append(order, ch.Author)
+		}
+		counts[ch.Author]++
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	return order[0], order[1:]
+}
+
+func message(group []change, coauthors []string) string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, ch := range group {
+		if !seen[ch.file] {
+			seen[ch.file] = true
+			files = append(files, ch.file)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Edit " + files[0])
+	switch len(files) {
+	case 1:
+	case 2:
+		sb.WriteString(" and " + files[1])
+	default:
+		fmt.Fprintf(&sb, " and %d other files", len(files)-1)
+	}
+	fmt.Fprintf(&sb, "\n\n%d changes between %s and %s.\n",
+		len(group), group[0].Time.Format("15:04:05"), group[len(group)-1].Time.Format("15:04:05"))
+	if len(coauthors) > 0 {
+		sb.WriteString("\n")
+		for _, name := range coauthors {
+			fmt.Fprintf(&sb, "Co-authored-by: %s <%s>\n", name, email(name))
+		}
+	}
+	return sb.String()
+}
+
+var nonEmail = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+func email(name string) string {
+	local := strings.Trim(nonEmail.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if local == "" {
+		local = "peer"
+	}
+	return local + "@users.noreply.waveland"
+}
+
+func git(dir string, env []string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s failed: %v\n%s", args[0], err, out)
+	}
+	return nil
+}
+
+func hasStaged(dir string) bool {
+	cmd := exec.Command("git", "diff", "--cached", "--quiet")
+	cmd.Dir = dir
+	return cmd.Run() != nil
+}
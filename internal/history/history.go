@@ -0,0 +1,177 @@
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultDir is where a session keeps its history, relative to the shared directory.
+var DefaultDir = filepath.Join(".waveland", "history")
+
+type Version struct {
+	N      int       `json:"n"`
+	Hash   string    `json:"hash"`
+	Size   int       `json:"size"`
+	Time   time.Time `json:"time"`
+	Author string    `json:"author"`
+}
+
+// Store keeps every version of every synced file. Contents live once under
+// objects/ keyed by their sha256, and each file has an append-only log of
+// the versions it went through.
+type Store struct {
+	dir  string
+	mu   sync.Mutex
+	last map[string]Version
+}
+
+func Open(dir string) (*Store, error) {
+	for _, sub := range []string{"objects", "log"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create history directory: %v", err)
+		}
+	}
+	return &Store{dir: dir, last: make(map[string]Version)}, nil
+}
+
+func Hash(content []byte) string {
+	h := sha256.Sum256(content)
+	return hex.EncodeToString(h[:])
+}
+
+func (s *Store) objectPath(hash string) string {
+	return filepath.Join(s.dir, "objects", hash[:2], hash[2:])
+}
+
+func (s *Store) logPath(file string) string {
+	return filepath.Join(s.dir, "log", file+".jsonl")
+}
+
+// Put stores content under its hash and returns the hash.
+func (s *Store) Put(content []byte) (string, error) {
+	hash := Hash(content)
+	path := s.objectPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return "", err
+	}
+	return hash, os.Rename(tmp, path)
+}
+
+func (s *Store) Get(hash string) ([]byte, error) {
+	if len(hash) < 3 {
+		return nil, fmt.Errorf("invalid hash %q", hash)
+	}
+	return os.ReadFile(s.objectPath(hash))
+}
+
+// Record appends a new version of file unless its content matches the latest
+// one. The returned bool reports whether a version was added.
+func (s *Store) Record(file string, content []byte, author string) (Version, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.last[file]
+	if !ok {
+		versions, err := s.Versions(file)
+		if err != nil {
+			return Version{}, false, err
+		}
+		if len(versions) > 0 {
+			prev = versions[len(versions)-1]
+		}
+	}
+
+	hash := Hash(content)
+	if prev.Hash == hash {
+		return prev, false, nil
+	}
+	if _, err := s.Put(content); err != nil {
+		return Version{}, false, fmt.Errorf("failed to store %s: %v", file, err)
+	}
+
+	v := Version{N: prev.N + 1, Hash: hash, Size: len(content), Time: time.Now(), Author: author}
+	line, err := json.Marshal(v)
+	if err != nil {
+		return Version{}, false, err
+	}
+	f, err := os.OpenFile(s.logPath(file), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return Version{}, false, err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return Version{}, false, err
+	}
+	s.last[file] = v
+	return v, true, nil
+}
+
+// Versions lists the recorded versions of file, oldest first.
+func (s *Store) Versions(file string) ([]Version, error) {
+	f, err := os.Open(s.logPath(file))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var versions []Version
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var v Version
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			return nil, fmt.Errorf("corrupt history for %s: %v", file, err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, scanner.Err()
+}
+
+// Lookup returns version n of file together with its content.
+func (s *Store) Lookup(file string, n int) (Version, []byte, error) {
+	versions, err := s.Versions(file)
+	if err != nil {
+		return Version{}, nil, err
+	}
+	if n < 1 || n > len(versions) {
+		return Version{}, nil, fmt.Errorf("%s has no version %d (%d recorded)", file, n, len(versions))
+	}
+	v := versions[n-1]
+	content, err := s.Get(v.Hash)
+	if err != nil {
+		return Version{}, nil, fmt.Errorf("missing content for %s@%d: %v", file, n, err)
+	}
+	return v, content, nil
+}
+
+// Files lists every file that has recorded history.
+func (s *Store) Files() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "log"))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if name := e.Name(); !e.IsDir() && strings.HasSuffix(name, ".jsonl") {
+			files = append(files, strings.TrimSuffix(name, ".jsonl"))
+		}
+	}
+	return files, nil
+}
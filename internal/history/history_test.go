@@ -0,0 +1,59 @@
+package history
+
+import (
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		content string
+		added   bool
+		n       int
+	}{
+		{"v1", true, 1},
+		{"v1", false, 1}, // unchanged content adds nothing
+		{"v2", true, 2},
+		{"v1", true, 3}, // reverting is a new version
+	}
+	for _, step := range steps {
+		v, added, err := s.Record("main.py", []byte(step.content), "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != step.added || v.N != step.n {
+			t.Errorf("Record(%q) = version %d, added %v, want %d, %v", step.content, v.N, added, step.n, step.added)
+		}
+	}
+
+	// a fresh store picks up where the log left off
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, added, _ := s.Record("main.py", []byte("v1"), "bob"); added || v.N != 3 {
+		t.Errorf("reopened store recorded version %d, added %v", v.N, added)
+	}
+
+	versions, err := s.Versions("main.py")
+	if err != nil || len(versions) != 3 {
+		t.Fatalf("Versions = %v, %v, want 3 versions", versions, err)
+	}
+	if versions[0].Hash != versions[2].Hash {
+		t.Error("same content stored under different hashes")
+	}
+	v, content, err := s.Lookup("main.py", 2)
+	if err != nil || string(content) != "v2" || v.Author != "alice" {
+		t.Errorf("Lookup(2) = %+v, %q, %v", v, content, err)
+	}
+	if _, _, err := s.Lookup("main.py", 4); err == nil {
+		t.Error("Lookup of a missing version succeeded")
+	}
+	if _, err := s.Get("x"); err == nil {
+		t.Error("Get of an invalid hash succeeded")
+	}
+}
@@ -0,0 +1,80 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Path is where the host keeps a log of what happened in its sessions:
+// phases, hints, chat and so on, for 'waveland report'.
+var Path = filepath.Join(".waveland", "journal.jsonl")
+
+// Kinds of events.
+const (
+	KindStart  = "start"
+	KindTimer  = "timer"
+	KindPhase  = "phase"
+	KindFreeze = "freeze"
+	KindHint   = "hint"
+	KindJoin   = "join"
+	KindLeave  = "leave"
+	KindChat   = "chat"
+	// KindCheck is an --on-change result, Text is "passed" or "failed".
+	KindCheck = "check"
+)
+
+type Event struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	Name string    `json:"name,omitempty"`
+	Text string    `json:"text,omitempty"`
+}
+
+var mu sync.Mutex
+
+// Add appends an event, stamped with the current time.
+func Add(kind, name, text string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(Event{Time: time.Now(), Kind: kind, Name: name, Text: text})
+}
+
+// Session returns the events of the latest session, starting with its
+// KindStart event.
+func Session() ([]Event, error) {
+	f, err := os.Open(Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if e.Kind == KindStart {
+			events = events[:0]
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,255 @@
+package judge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/problem"
+)
+
+const (
+	Accepted            = "Accepted"
+	WrongAnswer         = "Wrong Answer"
+	TimeLimitExceeded   = "Time Limit Exceeded"
+	MemoryLimitExceeded = "Memory Limit Exceeded"
+	RuntimeError        = "Runtime Error"
+	CompilationError    = "Compilation Error"
+)
+
+const compileTimeout = time.Minute
+
+// language is how the judge builds and runs a solution by default.
+type language struct {
+	problem.Command
+	// addressLimit is false for runtimes that reserve far more address
+	// space than they use; those are held to the memory limit by their peak
+	// resident size instead.
+	addressLimit bool
+}
+
+var languages = map[string]language{
+	"python":     {problem.Command{Run: "python3 {src}"}, true},
+	"javascript": {problem.Command{Run: "node {src}"}, false},
+	"go":         {problem.Command{Compile: "go build -o {bin} {src}", Run: "{bin}"}, false},
+	"c":          {problem.Command{Compile: "gcc -O2 -o {bin} {src} -lm", Run: "{bin}"}, true},
+	"cpp":        {problem.Command{Compile: "g++ -O2 -std=c++17 -o {bin} {src}", Run: "{bin}"}, true},
+	"java":       {problem.Command{Compile: "javac -d {dir} {src}", Run: "java -cp {dir} Main"}, false},
+	"rust":       {problem.Command{Compile: "rustc -O -o {bin} {src}", Run: "{bin}"}, true},
+}
+
+type Options struct {
+	Lang     string
+	Solution string
+	// ExamplesOnly skips the hidden tests.
+	ExamplesOnly bool
+}
+
+type Result struct {
+	Time     time.Time    `json:"time"`
+	Problem  string       `json:"problem"`
+	Lang     string       `json:"lang"`
+	Solution string       `json:"solution"`
+	Verdict  string       `json:"verdict"`
+	Passed   int          `json:"passed"`
+	Total    int          `json:"total"`
+	Tests    []TestResult `json:"tests"`
+	// CompileOutput is the compiler's complaint when the build failed.
+	CompileOutput string `json:"compile_output,omitempty"`
+}
+
+type TestResult struct {
+	Name    string        `json:"name"`
+	Hidden  bool          `json:"hidden"`
+	Verdict string        `json:"verdict"`
+	Time    time.Duration `json:"time"`
+	Memory  int64         `json:"memory"`
+	// Stderr and Output are kept for failed tests only, truncated.
+	Stderr string `json:"stderr,omitempty"`
+	Output string `json:"output,omitempty"`
+}
+
+// Run builds the solution in a scratch directory and runs it against every
+// case of the problem with its time and memory limits. Nothing is written to
+// the session directory, so hidden tests never get synced.
+func Run(ctx context.Context, p *problem.Problem, opts Options) (*Result, error) {
+	lang, ok := languages[opts.Lang]
+	if custom, ok2 := p.Commands[opts.Lang]; ok2 {
+		lang.Command, ok = custom, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("don't know how to run %s, add it to \"commands\" in the problem manifest", opts.Lang)
+	}
+	cases, err := p.Cases()
+	if err != nil {
+		return nil, err
+	}
+	if opts.ExamplesOnly {
+		var visible []problem.Case
+		for _, c := range cases {
+			if !c.Hidden {
+				visible = append(visible, c)
+			}
+		}
+		cases = visible
+	}
+
+	scratch, err := os.MkdirTemp("", "waveland-judge-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+	source, err := os.ReadFile(opts.Solution)
+	if err != nil {
+		return nil, err
+	}
+	src := filepath.Join(scratch, filepath.Base(opts.Solution))
+	if err := os.WriteFile(src, source, 0644); err != nil {
+		return nil, err
+	}
+	// the command is split before it is filled in, so paths with spaces
+	// stay one argument
+	r := strings.NewReplacer("{src}", src, "{bin}", filepath.Join(scratch, "solution"), "{dir}", scratch)
+	expand := func(command string) []string {
+		args := strings.Fields(command)
+		for i, arg := range args {
+			args[i] = r.Replace(arg)
+		}
+		return args
+	}
+
+	result := &Result{
+		Time:     time.Now(),
+		Problem:  p.Title,
+		Lang:     opts.Lang,
+		Solution: opts.Solution,
+		Total:    len(cases),
+	}
+
+	if lang.Compile != "" {
+		compileCtx, cancel := context.WithTimeout(ctx, compileTimeout)
+		args := expand(lang.Compile)
+		cmd := exec.CommandContext(compileCtx, args[0], args[1:]...)
+		cmd.Dir = scratch
+		out, err := cmd.CombinedOutput()
+		cancel()
+		if err != nil {
+			result.Verdict = CompilationError
+			result.CompileOutput = truncate(string(out))
+			if len(out) == 0 {
+				result.CompileOutput = err.Error()
+			}
+			return result, nil
+		}
+	}
+
+	timeLimit, memoryLimit := p.Limits()
+	args := expand(lang.Run)
+	for _, c := range cases {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		test := runCase(ctx, args, scratch, c, limits{timeLimit, memoryLimit, lang.addressLimit})
+		if test.Verdict == Accepted {
+			result.Passed++
+		} else if result.Verdict == "" {
+			result.Verdict = test.Verdict
+		}
+		result.Tests = append(result.Tests, test)
+	}
+	if result.Verdict == "" {
+		result.Verdict = Accepted
+	}
+	return result, nil
+}
+
+type limits struct {
+	time         time.Duration
+	memory       int64
+	addressLimit bool
+}
+
+func runCase(ctx context.Context, args []string, dir string, c problem.Case, l limits) TestResult {
+	ctx, cancel := context.WithTimeout(ctx, l.time)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := command(ctx, args, l)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(c.Input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	test := TestResult{
+		Name:   c.Name,
+		Hidden: c.Hidden,
+		Time:   time.Since(start),
+		Memory: maxRSS(cmd.ProcessState),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded) || cpuLimitHit(cmd.ProcessState):
+		test.Verdict = TimeLimitExceeded
+	case test.Memory > l.memory:
+		test.Verdict = MemoryLimitExceeded
+	case err != nil && l.addressLimit && outOfMemory(stderr.String()):
+		// with an address space limit, allocations fail instead
+		test.Verdict = MemoryLimitExceeded
+	case err != nil:
+		test.Verdict = RuntimeError
+		if !errors.As(err, &exitErr) {
+			stderr.WriteString(err.Error())
+		}
+	case !sameOutput(stdout.Bytes(), c.Output):
+		test.Verdict = WrongAnswer
+	default:
+		test.Verdict = Accepted
+	}
+	if test.Verdict != Accepted {
+		test.Stderr = truncate(stderr.String())
+		test.Output = truncate(stdout.String())
+	}
+	return test
+}
+
+func outOfMemory(stderr string) bool {
+	for _, sign := range []string{"MemoryError", "std::bad_alloc", "out of memory", "memory allocation of"} {
+		if strings.Contains(stderr, sign) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameOutput compares outputs token by token, so trailing spaces and
+// newlines don't matter.
+func sameOutput(got, want []byte) bool {
+	a, b := strings.Fields(string(got)), strings.Fields(string(want))
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func truncate(s string) string {
+	const limit = 4096
+	if len(s) > limit {
+		return s[:limit] + "\n... (truncated)"
+	}
+	return s
+}
@@ -0,0 +1,39 @@
+package judge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// command runs args under a shell that sets the rlimits first: address space
+// for the memory limit and CPU seconds as a backstop for the time limit.
+func command(ctx context.Context, args []string, l limits) *exec.Cmd {
+	script := fmt.Sprintf("ulimit -t %d", int(l.time.Seconds())+1)
+	if l.addressLimit {
+		script += fmt.Sprintf(" && ulimit -v %d", l.memory>>10)
+	}
+	script += ` && exec "$@"`
+	return exec.CommandContext(ctx, "sh", append([]string{"-c", script, "sh"}, args...)...)
+}
+
+// maxRSS is the peak resident size of the finished process in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if state == nil {
+		return 0
+	}
+	if usage, ok := state.SysUsage().(*syscall.Rusage); ok {
+		return usage.Maxrss << 10
+	}
+	return 0
+}
+
+func cpuLimitHit(state *os.ProcessState) bool {
+	if state == nil {
+		return false
+	}
+	status, ok := state.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGXCPU
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package judge
+
+import (
+	"context"
+	"os"
+	"os/exec"
+)
+
+// command runs args with the wall-clock limit only; memory limits are
+// enforced on Linux.
+func command(ctx context.Context, args []string, l limits) *exec.Cmd {
+	return exec.CommandContext(ctx, args[0], args[1:]...)
+}
+
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
+
+func cpuLimitHit(state *os.ProcessState) bool {
+	return false
+}
@@ -0,0 +1,46 @@
+package judge
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// LogPath collects every judge result of the session for the report.
+var LogPath = filepath.Join(".waveland", "judge.jsonl")
+
+func Append(r *Result) error {
+	if err := os.MkdirAll(filepath.Dir(LogPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(r)
+}
+
+// Results returns the logged results, oldest first.
+func Results() ([]Result, error) {
+	f, err := os.Open(LogPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []Result
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r Result
+		if err := json.Unmarshal(scanner.Bytes(), &r); err == nil {
+			results = append(results, r)
+		}
+	}
+	return results, scanner.Err()
+}
@@ -0,0 +1,154 @@
+// Package merkle keeps a hash tree over the shared files, so two sides can
+// tell whether they hold the same files, and if not where they differ,
+// without listing everything.
+//
+// Shared directories are flat, so the tree has two levels: files are spread
+// over 256 buckets by the hash of their name, and the root hashes the
+// buckets. Changing a file only rehashes its bucket and the root.
+package merkle
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+)
+
+type Tree struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	root    string
+}
+
+type bucket struct {
+	files map[string]string
+	hash  string
+}
+
+func New() *Tree {
+	return &Tree{buckets: make(map[string]*bucket)}
+}
+
+// Bucket returns the bucket a file name falls in.
+func Bucket(name string) string {
+	h := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(h[:1])
+}
+
+// Set records the content hash of a file.
+func (t *Tree) Set(name, hash string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := Bucket(name)
+	b := t.buckets[key]
+	if b == nil {
+		b = &bucket{files: make(map[string]string)}
+		t.buckets[key] = b
+	}
+	if b.files[name] == hash {
+		return
+	}
+	b.files[name] = hash
+	b.hash, t.root = "", ""
+}
+
+// Delete forgets a file.
+func (t *Tree) Delete(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := Bucket(name)
+	b := t.buckets[key]
+	if b == nil {
+		return
+	}
+	if _, ok := b.files[name]; !ok {
+		return
+	}
+	delete(b.files, name)
+	if len(b.files) == 0 {
+		delete(t.buckets, key)
+	} else {
+		b.hash = ""
+	}
+	t.root = ""
+}
+
+// Root hashes the whole tree. Equal roots mean equal files.
+func (t *Tree) Root() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.root == "" {
+		hashes := make(map[string]string, len(t.buckets))
+		for key, b := range t.buckets {
+			hashes[key] = b.sum()
+		}
+		t.root = sum(hashes)
+	}
+	return t.root
+}
+
+// Buckets returns the hash of every non-empty bucket.
+func (t *Tree) Buckets() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	hashes := make(map[string]string, len(t.buckets))
+	for key, b := range t.buckets {
+		hashes[key] = b.sum()
+	}
+	return hashes
+}
+
+// Files returns the files in a bucket with their content hashes.
+func (t *Tree) Files(key string) map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	files := make(map[string]string)
+	if b := t.buckets[key]; b != nil {
+		for name, hash := range b.files {
+			files[name] = hash
+		}
+	}
+	return files
+}
+
+// Diff lists the buckets whose hashes differ between two trees, including
+// buckets only one of them has.
+func Diff(a, b map[string]string) []string {
+	var keys []string
+	for key, hash := range a {
+		if b[key] != hash {
+			keys = append(keys, key)
+		}
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (b *bucket) sum() string {
+	if b.hash == "" {
+		b.hash = sum(b.files)
+	}
+	return b.hash
+}
+
+// sum hashes name/hash pairs in name order.
+func sum(entries map[string]string) string {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write([]byte(entries[name]))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,78 @@
+package merkle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func build(files map[string]string) *Tree {
+	t := New()
+	for name, hash := range files {
+		t.Set(name, hash)
+	}
+	return t
+}
+
+func TestRoot(t *testing.T) {
+	files := map[string]string{"a.py": "1", "b.py": "2", "c.md": "3"}
+	a, b := build(files), build(files)
+	if a.Root() != b.Root() {
+		t.Fatal("same files give different roots")
+	}
+
+	root := a.Root()
+	a.Set("b.py", "changed")
+	if a.Root() == root {
+		t.Error("root unchanged after a change")
+	}
+	a.Set("b.py", "2")
+	if a.Root() != root {
+		t.Error("root differs after undoing a change")
+	}
+	a.Set("d.py", "4")
+	a.Delete("d.py")
+	if a.Root() != root {
+		t.Error("root differs after adding and deleting a file")
+	}
+	if New().Root() == root {
+		t.Error("empty tree has the same root")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	files := map[string]string{"a.py": "1", "b.py": "2", "c.md": "3", "d.txt": "4"}
+	a, b := build(files), build(files)
+	if d := Diff(a.Buckets(), b.Buckets()); len(d) != 0 {
+		t.Fatalf("equal trees differ in %v", d)
+	}
+
+	b.Set("b.py", "changed")
+	b.Set("e.py", "5")
+	a.Delete("d.txt")
+	want := map[string]bool{Bucket("b.py"): true, Bucket("e.py"): true, Bucket("d.txt"): true}
+	got := Diff(a.Buckets(), b.Buckets())
+	if len(got) != len(want) {
+		t.Fatalf("Diff = %v, want the buckets of b.py, e.py and d.txt", got)
+	}
+	for _, key := range got {
+		if !want[key] {
+			t.Errorf("unexpected bucket %s in %v", key, got)
+		}
+	}
+	if !reflect.DeepEqual(got, Diff(b.Buckets(), a.Buckets())) {
+		t.Error("Diff depends on the order of its arguments")
+	}
+}
+
+func TestFiles(t *testing.T) {
+	tree := build(map[string]string{"a.py": "1", "b.py": "2"})
+	files := tree.Files(Bucket("a.py"))
+	if files["a.py"] != "1" {
+		t.Errorf("Files(%s) = %v, missing a.py", Bucket("a.py"), files)
+	}
+	for name := range files {
+		if Bucket(name) != Bucket("a.py") {
+			t.Errorf("%s is not in bucket %s", name, Bucket("a.py"))
+		}
+	}
+}
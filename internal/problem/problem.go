@@ -0,0 +1,409 @@
+package problem
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ManifestName is the file at the root of every problem package.
+const ManifestName = "problem.json"
+
+// StatementFile is what the statement is shared as in the session.
+const StatementFile = "PROBLEM.md"
+
+// Manifest describes a problem package:
+//
+//	{
+//	  "title": "Two Sum",
+//	  "statement": "statement.md",
+//	  "starters": {"python": "starters/solution.py", "go": "starters/main.go"},
+//	  "examples": [{"input": "2 7 11 15\n9\n", "output": "0 1\n"}],
+//	  "tests": "tests",
+//	  "hints": ["Think about what you need to remember.", "Try a hash map."],
+//	  "time_limit": "2s",
+//	  "memory_limit": 256
+//	}
+//
+// The tests directory holds hidden cases as NAME.in / NAME.out pairs. They
+// stay on the host and are never shared.
+type Manifest struct {
+	Title     string            `json:"title"`
+	Statement string            `json:"statement"`
+	Starters  map[string]string `json:"starters"`
+	Examples  []Example         `json:"examples"`
+	Tests     string            `json:"tests"`
+	// Hints are released one by one by the interviewer.
+	Hints []string `json:"hints"`
+	// TimeLimit is per test, as a Go duration; MemoryLimit is in megabytes.
+	TimeLimit   string `json:"time_limit"`
+	MemoryLimit int    `json:"memory_limit"`
+	// Commands overrides how a language is built and run by the judge.
+	Commands map[string]Command `json:"commands"`
+}
+
+// Command builds and runs a solution. {src} is replaced with the solution
+// file, {dir} with a scratch directory and {bin} with a file in it.
+type Command struct {
+	Compile string `json:"compile"`
+	Run     string `json:"run"`
+}
+
+type Example struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+type Problem struct {
+	Manifest
+	// Source is where the package was loaded from.
+	Source string
+	// Dir is the unpacked package on disk.
+	Dir string
+	tmp string
+}
+
+// Case is one input with its expected output.
+type Case struct {
+	Name   string
+	Input  []byte
+	Output []byte
+	Hidden bool
+}
+
+// Load opens a problem package from a directory, a .zip or a .tar.gz. A bare
+// name is looked up in ~/.waveland/problems.
+func Load(ref string) (*Problem, error) {
+	path, err := resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	p := &Problem{Source: path, Dir: path}
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		tmp, err := os.MkdirTemp("", "waveland-problem-")
+		if err != nil {
+			return nil, err
+		}
+		p.tmp = tmp
+		if err := unpack(path, tmp); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to unpack %s: %v", path, err)
+		}
+		p.Dir = tmp
+	}
+
+	// archives often wrap everything in one top-level directory
+	if _, err := os.Stat(filepath.Join(p.Dir, ManifestName)); os.IsNotExist(err) {
+		if entries, _ := os.ReadDir(p.Dir); len(entries) == 1 && entries[0].IsDir() {
+			p.Dir = filepath.Join(p.Dir, entries[0].Name())
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(p.Dir, ManifestName))
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("%s is not a problem package: %v", ref, err)
+	}
+	if err := json.Unmarshal(data, &p.Manifest); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("invalid %s: %v", ManifestName, err)
+	}
+	if p.Tests == "" {
+		p.Tests = "tests"
+	}
+	if p.Title == "" {
+		p.Title = strings.TrimSuffix(filepath.Base(ref), filepath.Ext(ref))
+	}
+	if err := p.checkPaths(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// checkPaths refuses manifest paths that lead out of the package: the
+// statement and starter code are shared, so they could leak any file the
+// host can read.
+func (m *Manifest) checkPaths() error {
+	paths := []string{m.Statement, m.Tests}
+	for _, lang := range m.Languages() {
+		paths = append(paths, m.Starters[lang])
+	}
+	for _, path := range paths {
+		if path != "" && !filepath.IsLocal(path) {
+			return fmt.Errorf("invalid %s: %s is outside the package", ManifestName, path)
+		}
+	}
+	return nil
+}
+
+// Limits returns the per-test time and memory (in bytes) limits.
+func (p *Problem) Limits() (time.Duration, int64) {
+	timeLimit := 2 * time.Second
+	if d, err := time.ParseDuration(p.TimeLimit); err == nil && d > 0 {
+		timeLimit = d
+	}
+	memoryLimit := int64(256)
+	if p.MemoryLimit > 0 {
+		memoryLimit = int64(p.MemoryLimit)
+	}
+	return timeLimit, memoryLimit << 20
+}
+
+// Cases returns the visible examples followed by the hidden tests, which
+// are read from the package and never written to the session directory.
+func (p *Problem) Cases() ([]Case, error) {
+	var cases []Case
+	for i, ex := range p.Examples {
+		cases = append(cases, Case{
+			Name:   fmt.Sprintf("example-%d", i+1),
+			Input:  []byte(ex.Input),
+			Output: []byte(ex.Output),
+		})
+	}
+
+	inputs, err := filepath.Glob(filepath.Join(p.Dir, p.Tests, "*.in"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(inputs)
+	for _, in := range inputs {
+		input, err := os.ReadFile(in)
+		if err != nil {
+			return nil, err
+		}
+		output, err := os.ReadFile(strings.TrimSuffix(in, ".in") + ".out")
+		if err != nil {
+			return nil, fmt.Errorf("test %s has no expected output: %v", filepath.Base(in), err)
+		}
+		cases = append(cases, Case{
+			Name:   strings.TrimSuffix(filepath.Base(in), ".in"),
+			Input:  input,
+			Output: output,
+			Hidden: true,
+		})
+	}
+	return cases, nil
+}
+
+// Close removes the unpacked copy of an archive.
+func (p *Problem) Close() error {
+	if p.tmp == "" {
+		return nil
+	}
+	return os.RemoveAll(p.tmp)
+}
+
+func resolve(ref string) (string, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return ref, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("problem %q not found", ref)
+	}
+	base := filepath.Join(home, ".waveland", "problems", ref)
+	for _, candidate := range []string{base, base + ".zip", base + ".tar.gz", base + ".tgz"} {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("problem %q not found (looked in the current directory and %s)", ref, filepath.Dir(base))
+}
+
+// Languages lists the languages the package has starter code for.
+func (m *Manifest) Languages() []string {
+	langs := make([]string, 0, len(m.Starters))
+	for lang := range m.Starters {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Language picks lang, or the only language when lang is empty.
+func (p *Problem) Language(lang string) (string, error) {
+	langs := p.Languages()
+	if lang == "" {
+		if len(langs) == 1 {
+			return langs[0], nil
+		}
+		return "", fmt.Errorf("pick a language with --lang (%s)", strings.Join(langs, ", "))
+	}
+	if _, ok := p.Starters[lang]; !ok {
+		return "", fmt.Errorf("%s has no starter code for %s (%s)", p.Title, lang, strings.Join(langs, ", "))
+	}
+	return lang, nil
+}
+
+// SolutionFile is the name the starter code for lang is shared under.
+func (p *Problem) SolutionFile(lang string) string {
+	return filepath.Base(p.Starters[lang])
+}
+
+// Materialize writes the statement and the starter code for lang into dir
+// and returns the names of the files to share. Existing solution files are
+// left alone so a restarted session doesn't wipe the candidate's work.
+func (p *Problem) Materialize(dir, lang string) ([]string, error) {
+	statement, err := p.StatementMarkdown()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, StatementFile), []byte(statement), 0644); err != nil {
+		return nil, err
+	}
+
+	solution := p.SolutionFile(lang)
+	dst := filepath.Join(dir, solution)
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		starter, err := os.ReadFile(filepath.Join(p.Dir, p.Starters[lang]))
+		if err != nil {
+			return nil, fmt.Errorf("missing starter code: %v", err)
+		}
+		if err := os.WriteFile(dst, starter, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return []string{StatementFile, solution}, nil
+}
+
+// StatementMarkdown renders the title, statement and visible examples.
+func (p *Problem) StatementMarkdown() (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", p.Title)
+	if p.Statement != "" {
+		body, err := os.ReadFile(filepath.Join(p.Dir, p.Statement))
+		if err != nil {
+			return "", fmt.Errorf("missing statement: %v", err)
+		}
+		sb.Write(body)
+		if !strings.HasSuffix(string(body), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	for i, ex := range p.Examples {
+		fmt.Fprintf(&sb, "\n## Example %d\n\nInput:\n\n```\n%s```\n\nOutput:\n\n```\n%s```\n",
+			i+1, withNewline(ex.Input), withNewline(ex.Output))
+	}
+	return sb.String(), nil
+}
+
+func withNewline(s string) string {
+	if strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
+
+func unpack(archive, dst string) error {
+	switch {
+	case strings.HasSuffix(archive, ".zip"):
+		return unzip(archive, dst)
+	case strings.HasSuffix(archive, ".tar.gz"), strings.HasSuffix(archive, ".tgz"):
+		return untar(archive, dst)
+	}
+	return fmt.Errorf("unsupported archive format")
+}
+
+// safeJoin refuses archive entries that would escape dst.
+func safeJoin(dst, name string) (string, error) {
+	path := filepath.Join(dst, name)
+	// "./" entries, as tar writes for the top directory, are dst itself
+	if path != filepath.Clean(dst) && !strings.HasPrefix(path, filepath.Clean(dst)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid path in archive: %s", name)
+	}
+	return path, nil
+}
+
+func unzip(archive, dst string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		path, err := safeJoin(dst, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(path, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func untar(archive, dst string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path, err := safeJoin(dst, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(path, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeFile(path string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, r)
+	return err
+}
@@ -0,0 +1,62 @@
+package problem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	dst := filepath.Join("tmp", "unpacked")
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"problem.json", filepath.Join(dst, "problem.json"), true},
+		{"tests/1.in", filepath.Join(dst, "tests", "1.in"), true},
+		{"./", dst, true},
+		{"./starter/main.py", filepath.Join(dst, "starter", "main.py"), true},
+		{"a/../b", filepath.Join(dst, "b"), true},
+		{"/etc/passwd", filepath.Join(dst, "etc", "passwd"), true},
+		{"../escape", "", false},
+		{"a/../../escape", "", false},
+		{"..", "", false},
+		{"../unpacked-sibling/x", "", false},
+	}
+	for _, tt := range tests {
+		got, err := safeJoin(dst, tt.name)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("safeJoin(%q) = %q, %v, want %q, ok %v", tt.name, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLoadPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		ok       bool
+	}{
+		{"local", `{"statement": "statement.md", "starters": {"go": "starters/main.go"}}`, true},
+		{"statement", `{"statement": "../../../.ssh/id_rsa"}`, false},
+		{"absolute statement", `{"statement": "/etc/passwd"}`, false},
+		{"starter", `{"starters": {"go": "main.go", "python": "../secret.py"}}`, false},
+		{"tests", `{"tests": "../other-problem/tests"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(tt.manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			p, err := Load(dir)
+			if (err == nil) != tt.ok {
+				t.Errorf("Load = %v, want ok %v", err, tt.ok)
+			}
+			if p != nil {
+				p.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SessionPath remembers which problem the session in this directory uses,
+// so 'waveland judge' needs no arguments.
+var SessionPath = filepath.Join(".waveland", "problem.json")
+
+type Session struct {
+	Source   string `json:"source"`
+	Lang     string `json:"lang"`
+	Solution string `json:"solution"`
+}
+
+func SaveSession(s Session) error {
+	if err := os.MkdirAll(filepath.Dir(SessionPath), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(SessionPath, data, 0644)
+}
+
+func LoadSession() (Session, error) {
+	var s Session
+	data, err := os.ReadFile(SessionPath)
+	if os.IsNotExist(err) {
+		return s, fmt.Errorf("no problem loaded here, start with 'waveland start --problem' or pass --problem")
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("invalid %s: %v", SessionPath, err)
+	}
+	return s, nil
+}
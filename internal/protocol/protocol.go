@@ -0,0 +1,354 @@
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Message types exchanged over the session websocket.
+const (
+	TypeHello   = "hello"
+	TypeWelcome = "welcome"
+	TypeJoin    = "join"
+	TypeLeave   = "leave"
+	TypeFile    = "file"
+	TypeCursor  = "cursor"
+	TypeChat    = "chat"
+
+	TypeTermOutput  = "term-output"
+	TypeTermInput   = "term-input"
+	TypeTermResize  = "term-resize"
+	TypeTermControl = "term-control"
+
+	TypeRunRequest = "run-request"
+	TypeRunStart   = "run-start"
+	TypeRunOutput  = "run-output"
+	TypeRunExit    = "run-exit"
+
+	TypeCheckResult = "check-result"
+
+	TypeTimer = "timer"
+	TypeHint  = "hint"
+
+	TypeDriver   = "driver"
+	TypeHandoff  = "handoff"
+	TypeRejected = "rejected"
+
+	TypeLock   = "lock"
+	TypeUnlock = "unlock"
+
+	TypeProposal = "proposal"
+	TypeReview   = "review"
+
+	TypeComment = "comment"
+
+	TypeManifest = "manifest"
+
+	TypeWant = "want"
+	TypeBlob = "blob"
+)
+
+// Roles in an interview session; see server.Interview.
+const (
+	RoleInterviewer = "interviewer"
+	RoleCandidate   = "candidate"
+)
+
+// Message is the envelope for everything sent over the websocket. From is
+// filled in by the server with the sender's name and cannot be spoofed.
+type Message struct {
+	Type string          `json:"type"`
+	From string          `json:"from,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Hello is the first message a client sends after connecting.
+type Hello struct {
+	Name string `json:"name"`
+	// Token proves the client is the host's own; see server.HostToken. A
+	// terminal connection sends the TerminalToken of the participant it
+	// watches for instead.
+	Token string `json:"token,omitempty"`
+	// Terminal connections only watch the host's shared shell; they don't
+	// sync files and aren't listed as participants.
+	Terminal bool `json:"terminal,omitempty"`
+}
+
+// Welcome answers a hello with the name the server settled on (it may be
+// suffixed to stay unique) and who else is in the session.
+type Welcome struct {
+	Name         string        `json:"name"`
+	Participants []Participant `json:"participants"`
+	// Chat is the recent chat history, so late joiners can catch up.
+	Chat []Chat `json:"chat,omitempty"`
+	// Terminal reports whether the host is sharing a shell.
+	Terminal bool `json:"terminal,omitempty"`
+	// Role is this participant's role in an interview session.
+	Role string `json:"role,omitempty"`
+	// Private lists file patterns that stay with the host. Clients refuse
+	// to receive files matching them.
+	Private []string `json:"private,omitempty"`
+	// Timer is the session timer, if the host set one.
+	Timer *Timer `json:"timer,omitempty"`
+	// Driver is set in mob mode.
+	Driver *Driver `json:"driver,omitempty"`
+	Locks  []Lock  `json:"locks,omitempty"`
+	// Review means changes from anyone but the host go to the host as
+	// proposals instead of being applied.
+	Review bool `json:"review,omitempty"`
+	// Comments are the review comments made so far.
+	Comments []Comment `json:"comments,omitempty"`
+	// TerminalToken lets this participant's 'waveland join --terminal'
+	// watch the shared shell under their name, and so be given control.
+	TerminalToken string `json:"terminal_token,omitempty"`
+}
+
+type Participant struct {
+	Name    string        `json:"name"`
+	Host    bool          `json:"host,omitempty"`
+	Role    string        `json:"role,omitempty"`
+	Joined  time.Time     `json:"joined"`
+	Latency time.Duration `json:"latency,omitempty"`
+}
+
+// File is a change to a shared file. Content that was sent before in the
+// session is not sent again: Hash, its sha256, refers to it instead, and
+// whoever doesn't have it asks with a Want. Hash is only set on references.
+// To, which only the host may set, sends the file to one participant
+// instead of everyone, see Manifest.
+type File struct {
+	Name    string `json:"name"`
+	Content []byte `json:"content,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+type Position struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Cursor is where a participant is in a file. It is relayed to editors only
+// and never written to disk. Name is filled in on the receiving side.
+type Cursor struct {
+	Name      string `json:"name,omitempty"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Col       int    `json:"col"`
+	Selection *Range `json:"selection,omitempty"`
+}
+
+// Chat is a message typed into a session terminal. Name and Time are set by
+// the server.
+type Chat struct {
+	Name string    `json:"name,omitempty"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+// TermData is raw output of the shared shell, or keystrokes sent to it.
+type TermData struct {
+	Data []byte `json:"data"`
+}
+
+type TermSize struct {
+	Rows int `json:"rows"`
+	Cols int `json:"cols"`
+}
+
+// TermControl tells a viewer whether it may type into the shared shell.
+type TermControl struct {
+	Name    string `json:"name"`
+	Allowed bool   `json:"allowed"`
+}
+
+// RunRequest asks the host to run a command in the session directory.
+type RunRequest struct {
+	ID   string   `json:"id"`
+	Args []string `json:"args"`
+}
+
+// RunStart announces that the host started a command, and for whom.
+type RunStart struct {
+	ID   string   `json:"id"`
+	Args []string `json:"args"`
+	By   string   `json:"by"`
+}
+
+type RunOutput struct {
+	ID     string `json:"id"`
+	Stream string `json:"stream"`
+	Data   []byte `json:"data"`
+}
+
+// RunExit ends a run. Code is -1 when the command could not run at all,
+// e.g. because the host declined it; Error says why.
+type RunExit struct {
+	ID    string `json:"id"`
+	Code  int    `json:"code"`
+	Error string `json:"error,omitempty"`
+}
+
+// CheckResult is the outcome of the host's --on-change command after a
+// batch of changes. Output is truncated to its last lines.
+type CheckResult struct {
+	Command  string        `json:"command"`
+	Passed   bool          `json:"passed"`
+	Code     int           `json:"code"`
+	Duration time.Duration `json:"duration"`
+	Output   string        `json:"output,omitempty"`
+}
+
+// Timer is the state of the host's session timer, sent whenever it changes.
+// The clock hasn't started while Started is zero. Frozen means only the host
+// may change files.
+type Timer struct {
+	Duration   time.Duration `json:"duration"`
+	Started    time.Time     `json:"started,omitempty"`
+	Phase      string        `json:"phase,omitempty"`
+	AutoFreeze bool          `json:"auto_freeze,omitempty"`
+	Frozen     bool          `json:"frozen,omitempty"`
+}
+
+// Remaining is the time left on a running timer.
+func (t Timer) Remaining() time.Duration {
+	if t.Started.IsZero() {
+		return t.Duration
+	}
+	if left := time.Until(t.Started.Add(t.Duration)); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// Hint is a hint the interviewer released to the candidate. File is
+// HINTS.md with the hint added; it comes with the hint rather than as a file
+// change, so neither mob mode nor a lock holds it back.
+type Hint struct {
+	N    int       `json:"n"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+	File []byte    `json:"file,omitempty"`
+}
+
+// Driver announces who may change files in mob mode; everyone else only
+// watches. An empty Name ends mob mode. Next and Rotate are set when the role
+// rotates on a timer.
+type Driver struct {
+	Name   string        `json:"name"`
+	Next   string        `json:"next,omitempty"`
+	Rotate time.Duration `json:"rotate,omitempty"`
+	Until  time.Time     `json:"until,omitempty"`
+}
+
+// Handoff passes the driver role on, to the next in line if To is empty.
+type Handoff struct {
+	To string `json:"to,omitempty"`
+}
+
+// Rejected tells a client the server dropped one of its messages. Hash is
+// set when content asked for with a Want can't be had.
+type Rejected struct {
+	Type   string `json:"type"`
+	File   string `json:"file,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// Lock claims a file for its owner; nobody else's changes to it are relayed.
+// Clients send lock and unlock with just File, the server announces every
+// change as a lock message, with an empty Owner when the file is free again.
+type Lock struct {
+	File  string `json:"file"`
+	Owner string `json:"owner,omitempty"`
+}
+
+// Review is the host's answer to a proposal: a proposal is a file message
+// the server turned over to the host instead of relaying it. Content is the
+// file as the host has it afterwards, so the proposer can catch up.
+type Review struct {
+	To       string `json:"to"`
+	ID       int    `json:"id"`
+	File     string `json:"file"`
+	Verdict  string `json:"verdict"`
+	Content  []byte `json:"content"`
+	Accepted []int  `json:"accepted,omitempty"`
+}
+
+// Review verdicts.
+const (
+	ReviewAccepted = "accepted"
+	ReviewPartial  = "partially accepted"
+	ReviewRejected = "rejected"
+)
+
+// Comment is a note on lines Line to EndLine (1-based) of a file. Every side
+// moves it along as the file changes; Outdated means its lines are gone. A
+// comment is sent again whole when it is resolved or deleted.
+type Comment struct {
+	ID       string    `json:"id"`
+	File     string    `json:"file"`
+	Line     int       `json:"line"`
+	EndLine  int       `json:"end_line"`
+	Text     string    `json:"text"`
+	Author   string    `json:"author"`
+	Time     time.Time `json:"time"`
+	Resolved bool      `json:"resolved,omitempty"`
+	Outdated bool      `json:"outdated,omitempty"`
+	Deleted  bool      `json:"deleted,omitempty"`
+}
+
+func Encode(msgType string, payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Message{Type: msgType, Data: data})
+}
+
+func Decode(raw []byte) (Message, error) {
+	var m Message
+	err := json.Unmarshal(raw, &m)
+	return m, err
+}
+
+// Unmarshal decodes the payload of m into v.
+func (m Message) Unmarshal(v any) error {
+	return json.Unmarshal(m.Data, v)
+}
+
+// Manifest settles the differences between a joiner's files and the
+// host's. The joiner sends the Root and Buckets of its hash tree (see the
+// merkle package); the host answers To the joiner with its own, plus the
+// Files in every bucket that differs, unless the roots match. The joiner
+// then sends the names it Wants, and the host sends those files To the
+// joiner as file messages. This happens once, when joining.
+type Manifest struct {
+	To      string            `json:"to,omitempty"`
+	Root    string            `json:"root,omitempty"`
+	Buckets map[string]string `json:"buckets,omitempty"`
+	Files   []ManifestEntry   `json:"files,omitempty"`
+	Want    []string          `json:"want,omitempty"`
+}
+
+type ManifestEntry struct {
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// Want asks for content by hash, see File.
+type Want struct {
+	Hashes []string `json:"hashes"`
+}
+
+// Blob answers a Want.
+type Blob struct {
+	Hash    string `json:"hash"`
+	Content []byte `json:"content"`
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package pty
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errUnsupported = errors.New("shared terminals are only supported on Linux")
+
+func Start(cmd *exec.Cmd) (*os.File, error) {
+	return nil, errUnsupported
+}
+
+func Setsize(f *os.File, rows, cols int) error {
+	return errUnsupported
+}
+
+func Size(fd int) (rows, cols int, err error) {
+	return 0, 0, errUnsupported
+}
+
+func MakeRaw(fd int) (func(), error) {
+	return nil, errUnsupported
+}
+
+func NotifyResize(ctx context.Context, fn func()) {}
@@ -0,0 +1,155 @@
+package record
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Player re-applies a recording to a directory in (scaled) real time.
+type Player struct {
+	rec *Recording
+	dir string
+
+	mu     sync.Mutex
+	pos    time.Duration
+	speed  float64
+	paused bool
+	next   int
+
+	// OnEvent is called for every change written to the directory.
+	OnEvent func(Event)
+	// OnEnd is called when playback reaches the end of the recording.
+	OnEnd func()
+}
+
+func NewPlayer(rec *Recording, dir string) (*Player, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Player{rec: rec, dir: dir, speed: 1}, nil
+}
+
+func (p *Player) Run(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	last := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			p.mu.Lock()
+			if !p.paused {
+				p.pos += time.Duration(float64(now.Sub(last)) * p.speed)
+			}
+			last = now
+
+			var applied []Event
+			for p.next < len(p.rec.Events) && p.rec.Events[p.next].At <= p.pos {
+				ev := p.rec.Events[p.next]
+				if err := p.write(ev.File, ev.Content); err != nil {
+					p.mu.Unlock()
+					return err
+				}
+				applied = append(applied, ev)
+				p.next++
+			}
+			ended := !p.paused && p.next == len(p.rec.Events)
+			if ended {
+				p.pos = p.rec.Duration()
+				p.paused = true
+			}
+			p.mu.Unlock()
+
+			for _, ev := range applied {
+				if p.OnEvent != nil {
+					p.OnEvent(ev)
+				}
+			}
+			if ended && p.OnEnd != nil {
+				p.OnEnd()
+			}
+		}
+	}
+}
+
+func (p *Player) write(file string, content []byte) error {
+	return os.WriteFile(filepath.Join(p.dir, filepath.Base(file)), content, 0644)
+}
+
+// TogglePause pauses or resumes playback and reports whether it is now paused.
+// Resuming at the end of the recording starts over from the beginning.
+func (p *Player) TogglePause() (bool, error) {
+	p.mu.Lock()
+	atEnd := p.paused && p.next == len(p.rec.Events)
+	p.mu.Unlock()
+	if atEnd {
+		if err := p.Seek(0); err != nil {
+			return true, err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = !p.paused
+	return p.paused, nil
+}
+
+func (p *Player) SetSpeed(speed float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if speed < 0.125 {
+		speed = 0.125
+	}
+	if speed > 64 {
+		speed = 64
+	}
+	p.speed = speed
+}
+
+func (p *Player) Speed() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.speed
+}
+
+func (p *Player) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pos
+}
+
+// Seek rewrites every file in the directory to its state at offset at.
+func (p *Player) Seek(at time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if at < 0 {
+		at = 0
+	}
+	if total := p.rec.Duration(); at > total {
+		at = total
+	}
+
+	state := p.rec.StateAt(at)
+	for _, file := range p.rec.Files() {
+		if content, ok := state[file]; ok {
+			if err := p.write(file, content); err != nil {
+				return err
+			}
+		} else if err := os.Remove(filepath.Join(p.dir, filepath.Base(file))); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	p.pos = at
+	p.next = 0
+	for p.next < len(p.rec.Events) && p.rec.Events[p.next].At <= at {
+		p.next++
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package record
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const formatName = "wlrec"
+
+// A recording is a gzip-compressed stream of JSON lines: a header followed by
+// one line per synced change. Each distinct content is stored only the first
+// time it appears; later changes to the same content only carry its hash.
+type header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Start   time.Time `json:"start"`
+}
+
+type Event struct {
+	At      time.Duration `json:"-"`
+	Millis  int64         `json:"t"`
+	File    string        `json:"f"`
+	Author  string        `json:"a"`
+	Hash    string        `json:"h"`
+	Content []byte        `json:"c,omitempty"`
+}
+
+type Writer struct {
+	mu    sync.Mutex
+	file  *os.File
+	gz    *gzip.Writer
+	enc   *json.Encoder
+	start time.Time
+	seen  map[string]bool
+}
+
+func Create(path string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %v", err)
+	}
+	gz := gzip.NewWriter(f)
+	w := &Writer{
+		file:  f,
+		gz:    gz,
+		enc:   json.NewEncoder(gz),
+		start: time.Now(),
+		seen:  make(map[string]bool),
+	}
+	if err := w.enc.Encode(header{Format: formatName, Version: 1, Start: w.start}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, w.gz.Flush()
+}
+
+// Add appends a change to the recording. It flushes after every event so an
+// interrupted session still leaves a readable file.
+func (w *Writer) Add(file, author string, content []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	ev := Event{
+		Millis: time.Since(w.start).Milliseconds(),
+		File:   file,
+		Author: author,
+		Hash:   hash,
+	}
+	if !w.seen[hash] {
+		ev.Content = content
+		w.seen[hash] = true
+	}
+	if err := w.enc.Encode(ev); err != nil {
+		return err
+	}
+	return w.gz.Flush()
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.gz.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+type Recording struct {
+	Start  time.Time
+	Events []Event
+}
+
+// Duration is the offset of the last recorded change.
+func (r *Recording) Duration() time.Duration {
+	if len(r.Events) == 0 {
+		return 0
+	}
+	return r.Events[len(r.Events)-1].At
+}
+
+// StateAt returns the content of every file as of offset at.
+func (r *Recording) StateAt(at time.Duration) map[string][]byte {
+	state := make(map[string][]byte)
+	for _, ev := range r.Events {
+		if ev.At > at {
+			break
+		}
+		state[ev.File] = ev.Content
+	}
+	return state
+}
+
+// Files lists every file that appears in the recording.
+func (r *Recording) Files() []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, ev := range r.Events {
+		if !seen[ev.File] {
+			seen[ev.File] = true
+			files = append(files, ev.File)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Open reads a whole recording, resolving every event to its full content.
+func Open(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a waveland recording: %v", path, err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 32*1024*1024)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+	var h header
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil || h.Format != formatName {
+		return nil, fmt.Errorf("%s is not a waveland recording", path)
+	}
+
+	rec := &Recording{Start: h.Start}
+	blobs := make(map[string][]byte)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// a session killed mid-write can leave a truncated last line
+			break
+		}
+		if ev.Content != nil {
+			blobs[ev.Hash] = ev.Content
+		} else if ev.Content = blobs[ev.Hash]; ev.Content == nil {
+			ev.Content = []byte{}
+		}
+		ev.At = time.Duration(ev.Millis) * time.Millisecond
+		rec.Events = append(rec.Events, ev)
+	}
+	return rec, nil
+}
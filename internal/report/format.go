@@ -0,0 +1,112 @@
+package report
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"text/template"
+)
+
+var funcs = template.FuncMap{"offset": offset, "fence": fence, "line": line}
+
+var markdownTemplate = template.Must(template.New("md").Funcs(funcs).Parse(`# {{.Title}}
+
+- Date: {{.Start.Local.Format "2006-01-02 15:04"}}
+- Duration: {{.Duration}}
+{{with .Interviewer}}- Interviewer: {{.}}
+{{end}}{{with .Candidate}}- Candidate: {{.}}
+{{end}}- Time to first passing run: {{if .FirstPass}}{{offset .FirstPass}}{{else}}never passed{{end}}
+- Hints used: {{len .Hints}}
+{{with .Phases}}
+## Phases
+
+{{range .}}- {{offset .At}} {{.Text}}
+{{end}}{{end}}{{with .Verdicts}}
+## Judge verdicts
+
+| Time | Solution | Verdict | Passed |
+|------|----------|---------|--------|
+{{range .}}| {{offset .At}} | {{.Solution}} | {{.Verdict}} | {{.Passed}}/{{.Total}} |
+{{end}}{{end}}{{with .Hints}}
+## Hints
+
+{{range .}}- {{offset .At}} {{.Text}}
+{{end}}{{end}}{{with .Edits}}
+## Edit timeline
+
+| Time | File | Version | Author | Size |
+|------|------|---------|--------|------|
+{{range .}}| {{offset .At}} | {{.File}} | {{.Version}} | {{.Author}} | {{.Size}} |
+{{end}}{{end}}{{with .Chat}}
+## Chat
+
+{{range .}}- {{offset .At}} **{{.Name}}**: {{.Text}}
+{{end}}{{end}}{{with .Files}}
+## Final code
+{{range .}}
+### {{.Name}}
+
+{{fence .Content}}{{.Lang}}
+{{line .Content}}{{fence .Content}}
+{{end}}{{end}}
+## Rubric
+
+{{.Rubric}}
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap(funcs)).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+<li>Date: {{.Start.Local.Format "2006-01-02 15:04"}}</li>
+<li>Duration: {{.Duration}}</li>
+{{with .Interviewer}}<li>Interviewer: {{.}}</li>{{end}}
+{{with .Candidate}}<li>Candidate: {{.}}</li>{{end}}
+<li>Time to first passing run: {{if .FirstPass}}{{offset .FirstPass}}{{else}}never passed{{end}}</li>
+<li>Hints used: {{len .Hints}}</li>
+</ul>
+{{with .Phases}}<h2>Phases</h2>
+<ul>{{range .}}<li>{{offset .At}} {{.Text}}</li>{{end}}</ul>{{end}}
+{{with .Verdicts}}<h2>Judge verdicts</h2>
+<table><tr><th>Time</th><th>Solution</th><th>Verdict</th><th>Passed</th></tr>
+{{range .}}<tr><td>{{offset .At}}</td><td>{{.Solution}}</td><td>{{.Verdict}}</td><td>{{.Passed}}/{{.Total}}</td></tr>
+{{end}}</table>{{end}}
+{{with .Hints}}<h2>Hints</h2>
+<ul>{{range .}}<li>{{offset .At}} {{.Text}}</li>{{end}}</ul>{{end}}
+{{with .Edits}}<h2>Edit timeline</h2>
+<table><tr><th>Time</th><th>File</th><th>Version</th><th>Author</th><th>Size</th></tr>
+{{range .}}<tr><td>{{offset .At}}</td><td>{{.File}}</td><td>{{.Version}}</td><td>{{.Author}}</td><td>{{.Size}}</td></tr>
+{{end}}</table>{{end}}
+{{with .Chat}}<h2>Chat</h2>
+<ul>{{range .}}<li>{{offset .At}} <b>{{.Name}}</b>: {{.Text}}</li>{{end}}</ul>{{end}}
+{{with .Files}}<h2>Final code</h2>
+{{range .}}<h3>{{.Name}}</h3>
+<pre><code>{{.Content}}</code></pre>
+{{end}}{{end}}
+<h2>Rubric</h2>
+<pre>{{.Rubric}}</pre>
+</body>
+</html>
+`))
+
+func (r *Report) Markdown() ([]byte, error) {
+	var buf bytes.Buffer
+	err := markdownTemplate.Execute(&buf, r)
+	return buf.Bytes(), err
+}
+
+func (r *Report) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, r)
+	return buf.Bytes(), err
+}
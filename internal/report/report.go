@@ -0,0 +1,285 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/go-johnnyhe/waveland/internal/journal"
+	"github.com/go-johnnyhe/waveland/internal/judge"
+	"github.com/go-johnnyhe/waveland/internal/problem"
+)
+
+// Report is everything known about the latest session in this directory.
+type Report struct {
+	Title        string
+	Start        time.Time
+	End          time.Time
+	Interviewer  string
+	Participants []string
+	Phases       []Entry
+	Edits        []Edit
+	// FirstPass is when a judge run or --on-change check first passed,
+	// relative to the start; zero if nothing passed.
+	FirstPass time.Duration
+	Verdicts  []Run
+	Hints     []Entry
+	Chat      []Entry
+	Files     []File
+	Rubric    string
+}
+
+// Entry is something that happened, At after the start.
+type Entry struct {
+	At   time.Duration
+	Name string
+	Text string
+}
+
+type Edit struct {
+	At      time.Duration
+	File    string
+	Author  string
+	Version int
+	Size    int
+}
+
+// Run is a judge result, At after the start.
+type Run struct {
+	At time.Duration
+	judge.Result
+}
+
+type File struct {
+	Name    string
+	Lang    string
+	Content string
+}
+
+// Duration is how long the session took.
+func (r *Report) Duration() time.Duration {
+	return r.End.Sub(r.Start).Round(time.Second)
+}
+
+// Verdict is the latest judge verdict, or "" if the solution wasn't judged.
+func (r *Report) Verdict() string {
+	if len(r.Verdicts) == 0 {
+		return ""
+	}
+	return r.Verdicts[len(r.Verdicts)-1].Verdict
+}
+
+// Candidate names everyone but the interviewer.
+func (r *Report) Candidate() string {
+	var others []string
+	for _, p := range r.Participants {
+		if p != r.Interviewer {
+			others = append(others, p)
+		}
+	}
+	return strings.Join(others, ", ")
+}
+
+// skipFiles are shared by waveland itself, not written by the candidate.
+var skipFiles = map[string]bool{problem.StatementFile: true, "HINTS.md": true}
+
+// Build gathers the report from the journal, the file history and the judge
+// log. rubric is a text/template for the rubric section; empty uses the
+// built-in one.
+func Build(store *history.Store, rubric string) (*Report, error) {
+	events, err := journal.Session()
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 || events[0].Kind != journal.KindStart {
+		return nil, fmt.Errorf("no session found, reports cover sessions started here with 'waveland start'")
+	}
+
+	r := &Report{Title: "Coding session", Start: events[0].Time, End: events[len(events)-1].Time, Interviewer: events[0].Name}
+	if session, err := problem.LoadSession(); err == nil {
+		if p, err := problem.Load(session.Source); err == nil {
+			r.Title = p.Title
+			p.Close()
+		}
+	}
+	at := func(t time.Time) time.Duration {
+		if t.After(r.End) {
+			r.End = t
+		}
+		return t.Sub(r.Start).Round(time.Second)
+	}
+	pass := func(t time.Time) {
+		if d := at(t); r.FirstPass == 0 || d < r.FirstPass {
+			r.FirstPass = d
+		}
+	}
+
+	seen := map[string]bool{}
+	if r.Interviewer != "" {
+		seen[r.Interviewer] = true
+		r.Participants = append(r.Participants, r.Interviewer)
+	}
+	for _, e := range events {
+		switch e.Kind {
+		case journal.KindJoin:
+			if !seen[e.Name] {
+				seen[e.Name] = true
+				r.Participants = append(r.Participants, e.Name)
+			}
+		case journal.KindPhase:
+			r.Phases = append(r.Phases, Entry{At: at(e.Time), Text: e.Text})
+		case journal.KindHint:
+			r.Hints = append(r.Hints, Entry{At: at(e.Time), Name: e.Name, Text: e.Text})
+		case journal.KindChat:
+			r.Chat = append(r.Chat, Entry{At: at(e.Time), Name: e.Name, Text: e.Text})
+		case journal.KindCheck:
+			if e.Text == "passed" {
+				pass(e.Time)
+			}
+		}
+	}
+
+	results, err := judge.Results()
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		if result.Time.Before(r.Start) {
+			continue
+		}
+		r.Verdicts = append(r.Verdicts, Run{At: at(result.Time), Result: result})
+		if result.Verdict == judge.Accepted {
+			pass(result.Time)
+		}
+	}
+
+	if store != nil {
+		if err := r.addFiles(store, at); err != nil {
+			return nil, err
+		}
+	}
+
+	if rubric == "" {
+		rubric = defaultRubric
+	}
+	tmpl, err := template.New("rubric").Parse(rubric)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rubric template: %v", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, r); err != nil {
+		return nil, fmt.Errorf("invalid rubric template: %v", err)
+	}
+	r.Rubric = strings.TrimSpace(sb.String())
+	return r, nil
+}
+
+func (r *Report) addFiles(store *history.Store, at func(time.Time) time.Duration) error {
+	files, err := store.Files()
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if skipFiles[name] {
+			continue
+		}
+		versions, err := store.Versions(name)
+		if err != nil {
+			return err
+		}
+		var last *history.Version
+		for i, v := range versions {
+			if v.Time.Before(r.Start) {
+				continue
+			}
+			r.Edits = append(r.Edits, Edit{At: at(v.Time), File: name, Author: v.Author, Version: v.N, Size: v.Size})
+			last = &versions[i]
+		}
+		if last == nil {
+			continue
+		}
+		content, err := store.Get(last.Hash)
+		if err != nil {
+			return err
+		}
+		r.Files = append(r.Files, File{Name: name, Lang: language(name), Content: string(content)})
+	}
+	sort.SliceStable(r.Edits, func(i, j int) bool { return r.Edits[i].At < r.Edits[j].At })
+	return nil
+}
+
+// LoadRubric reads a rubric template, falling back to .waveland/rubric.md.
+// It returns "" when there is none, for the built-in one.
+func LoadRubric(path string) (string, error) {
+	if path == "" {
+		path = filepath.Join(".waveland", "rubric.md")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return "", nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+const defaultRubric = `| Area | Score (1-4) | Notes |
+|------|-------------|-------|
+| Problem solving | | |
+| Coding | | |
+| Testing | | |
+| Communication | | |
+
+Final verdict: {{with .Verdict}}{{.}}{{else}}not judged{{end}}. Hints used: {{len .Hints}}.
+
+Overall:
+`
+
+func language(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".py":
+		return "python"
+	case ".go":
+		return "go"
+	case ".js":
+		return "javascript"
+	case ".ts":
+		return "typescript"
+	case ".java":
+		return "java"
+	case ".c", ".h":
+		return "c"
+	case ".cc", ".cpp", ".hpp":
+		return "cpp"
+	case ".rs":
+		return "rust"
+	case ".md":
+		return "markdown"
+	}
+	return ""
+}
+
+func offset(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// line makes sure s ends with a newline.
+func line(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
+
+// fence picks a code fence longer than any backtick run in s.
+func fence(s string) string {
+	f := "```"
+	for strings.Contains(s, f) {
+		f += "`"
+	}
+	return f
+}
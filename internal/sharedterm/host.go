@@ -0,0 +1,122 @@
+package sharedterm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync/atomic"
+
+	"github.com/go-johnnyhe/waveland/internal/console"
+	"github.com/go-johnnyhe/waveland/internal/pty"
+	"github.com/go-johnnyhe/waveland/server"
+)
+
+// detachKey (Ctrl-]) returns from the shared shell to the session console.
+const detachKey = 0x1d
+
+// Host is a shell running on a pseudo-terminal whose output is broadcast to
+// every terminal viewer in the session.
+type Host struct {
+	pty      *os.File
+	attached atomic.Bool
+}
+
+// Share starts shell in the current directory and relays it through the
+// session server until ctx is done or the shell exits.
+func Share(ctx context.Context, shell string) (*Host, error) {
+	cmd := exec.Command(shell)
+	cmd.Env = append(os.Environ(), "WAVELAND_SHELL=1")
+	f, err := pty.Start(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start shared shell: %v", err)
+	}
+	h := &Host{pty: f}
+	if rows, cols, err := pty.Size(int(os.Stdin.Fd())); err == nil {
+		pty.Setsize(f, rows, cols)
+	} else {
+		pty.Setsize(f, 24, 80)
+	}
+
+	server.ShareTerminal(
+		func(name string, data []byte) {
+			f.Write(data)
+		},
+		func(name string, rows, cols int) {
+			pty.Setsize(f, rows, cols)
+		},
+	)
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := f.Read(buf)
+			if n > 0 {
+				server.BroadcastTerminal(buf[:n])
+				if h.attached.Load() {
+					os.Stdout.Write(buf[:n])
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		cmd.Wait()
+		server.StopSharingTerminal()
+		if h.attached.Load() {
+			fmt.Print("\r\nShared shell exited, press any key\r\n")
+		} else {
+			fmt.Println("Shared shell exited")
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		cmd.Process.Kill()
+		f.Close()
+	}()
+	pty.NotifyResize(ctx, func() {
+		if h.attached.Load() {
+			h.fitToHost()
+		}
+	})
+	return h, nil
+}
+
+func (h *Host) fitToHost() {
+	if rows, cols, err := pty.Size(int(os.Stdin.Fd())); err == nil {
+		pty.Setsize(h.pty, rows, cols)
+	}
+}
+
+// Attach connects the host's own terminal to the shared shell until the
+// detach key is pressed.
+func (h *Host) Attach(con *console.Console) error {
+	restore, err := pty.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	fmt.Print("Attached to the shared shell, press Ctrl-] to return\r\n")
+	os.Stdout.Write(server.Scrollback())
+	h.fitToHost()
+	h.attached.Store(true)
+
+	con.Raw(func(chunk []byte) bool {
+		if i := bytes.IndexByte(chunk, detachKey); i >= 0 {
+			h.pty.Write(chunk[:i])
+			h.attached.Store(false)
+			restore()
+			fmt.Println("\nDetached from the shared shell")
+			return false
+		}
+		if _, err := h.pty.Write(chunk); err != nil {
+			h.attached.Store(false)
+			restore()
+			return false
+		}
+		return true
+	})
+	return nil
+}
@@ -0,0 +1,154 @@
+package sharedterm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/go-johnnyhe/waveland/internal/pty"
+	"github.com/go-johnnyhe/waveland/internal/wsutil"
+	"github.com/gorilla/websocket"
+)
+
+// TokenPath is where 'waveland join' keeps its terminal token for the
+// session at url, see protocol.Welcome.
+func TokenPath(url string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "waveland", "terminal-"+hex.EncodeToString(sum[:8]))
+}
+
+// View shows the host's shared shell in this terminal. Keystrokes are only
+// forwarded once the host gives us control with /control, which needs the
+// token of our own participant in the session; without one we only watch.
+func View(ctx context.Context, conn *websocket.Conn, name, token string) error {
+	p := wsutil.NewPeer(conn)
+	send := func(msgType string, payload any) error {
+		out, err := protocol.Encode(msgType, payload)
+		if err != nil {
+			return err
+		}
+		return p.Write(websocket.TextMessage, out)
+	}
+	if err := send(protocol.TypeHello, protocol.Hello{Name: name, Token: token, Terminal: true}); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	var restore func()
+	control := func(allowed bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if allowed && restore == nil {
+			r, err := pty.MakeRaw(int(os.Stdin.Fd()))
+			if err != nil {
+				fmt.Printf("\r\n[you were given control but can't type here: %v]\r\n", err)
+				return
+			}
+			restore = r
+			fmt.Print("\r\n[you have control of the shared shell, Ctrl-] to leave]\r\n")
+			if rows, cols, err := pty.Size(int(os.Stdin.Fd())); err == nil {
+				send(protocol.TypeTermResize, protocol.TermSize{Rows: rows, Cols: cols})
+			}
+		} else if !allowed && restore != nil {
+			restore()
+			restore = nil
+			fmt.Print("\r\n[control of the shared shell was taken back]\r\n")
+		}
+	}
+	inControl := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return restore != nil
+	}
+	defer control(false)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			_, raw, err := p.ReadMessage()
+			if err != nil {
+				errs <- fmt.Errorf("connection closed: %v", err)
+				return
+			}
+			msg, err := protocol.Decode(raw)
+			if err != nil {
+				continue
+			}
+			switch msg.Type {
+			case protocol.TypeWelcome:
+				var welcome protocol.Welcome
+				if msg.Unmarshal(&welcome) == nil && !welcome.Terminal {
+					errs <- fmt.Errorf("the host is not sharing a terminal (waveland start --shell)")
+					return
+				}
+				if welcome.Name != "" {
+					name = welcome.Name
+				}
+				if token == "" {
+					fmt.Println("Watching the shared shell (read-only: join the session in another window first to be given control)")
+				} else {
+					fmt.Println("Watching the shared shell (read-only until the host runs /control " + name + ")")
+				}
+			case protocol.TypeTermOutput:
+				var out protocol.TermData
+				if msg.Unmarshal(&out) == nil {
+					os.Stdout.Write(out.Data)
+				}
+			case protocol.TypeTermControl:
+				var tc protocol.TermControl
+				if msg.Unmarshal(&tc) == nil && tc.Name == name {
+					control(tc.Allowed)
+				}
+			}
+		}
+	}()
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			if !inControl() {
+				continue
+			}
+			chunk := buf[:n]
+			if i := bytes.IndexByte(chunk, detachKey); i >= 0 {
+				send(protocol.TypeTermInput, protocol.TermData{Data: chunk[:i]})
+				cancel()
+				return
+			}
+			send(protocol.TypeTermInput, protocol.TermData{Data: chunk})
+		}
+	}()
+
+	pty.NotifyResize(ctx, func() {
+		if !inControl() {
+			return
+		}
+		if rows, cols, err := pty.Size(int(os.Stdin.Fd())); err == nil {
+			send(protocol.TypeTermResize, protocol.TermSize{Rows: rows, Cols: cols})
+		}
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
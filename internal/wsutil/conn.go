@@ -3,6 +3,7 @@ package wsutil
 import (
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -14,6 +15,13 @@ const waitTime = 10 * time.Second
 type Peer struct {
 	*websocket.Conn
 	mu sync.Mutex
+
+	// Name, Host, Terminal and Joined are set once the peer has said hello.
+	Name     string
+	Host     bool
+	Terminal bool
+	Joined   time.Time
+	rtt      atomic.Int64
 }
 
 func NewPeer(conn *websocket.Conn) *Peer {
@@ -31,4 +39,13 @@ func (p *Peer) Write(msgType int, msg []byte) error {
 
 	p.SetWriteDeadline(time.Now().Add(waitTime))
 	return p.WriteMessage(msgType, msg)
-}
\ No newline at end of file
+}
+
+// SetRTT stores the last measured ping round trip.
+func (p *Peer) SetRTT(d time.Duration) {
+	p.rtt.Store(int64(d))
+}
+
+func (p *Peer) RTT() time.Duration {
+	return time.Duration(p.rtt.Load())
+}
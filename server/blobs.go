@@ -0,0 +1,297 @@
+package server
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/go-johnnyhe/waveland/internal/wsutil"
+	"github.com/gorilla/websocket"
+)
+
+// The server keeps recently relayed file contents by their hash, and which
+// contents each participant has, so content goes to everyone once: copies,
+// reverts and undo are relayed as references. Contents beyond blobsLimit
+// are evicted, least recently used first, and asked for again if needed.
+var blobs = make(map[string]*list.Element)
+var blobOrder = list.New()
+var blobBytes int
+var has = make(map[*wsutil.Peer]map[string]bool)
+var blobsMutex = &sync.Mutex{}
+
+var blobsLimit = 64 * 1024 * 1024
+
+type blob struct {
+	hash    string
+	content []byte
+}
+
+// waiting holds what is waiting for content the server doesn't have: file
+// messages that referred to it, and participants that asked for it. What
+// waits longer than blobWait is given up, and the participant told.
+var waiting = make(map[string][]*waitingFile)
+
+var blobWait = 30 * time.Second
+
+// waitingFile is a file message p sent about name, or p asking for the
+// content when raw is nil.
+type waitingFile struct {
+	p    *wsutil.Peer
+	name string
+	raw  []byte
+}
+
+// wait must be called with blobsMutex held.
+func wait(hash string, w *waitingFile) {
+	waiting[hash] = append(waiting[hash], w)
+	time.AfterFunc(blobWait, func() { giveUp(hash, w) })
+}
+
+// unwait drops what drop matches from waiting for hash, and reports whether
+// there was any. It must be called with blobsMutex held.
+func unwait(hash string, drop func(*waitingFile) bool) bool {
+	pending := waiting[hash]
+	kept := pending[:0]
+	for _, w := range pending {
+		if !drop(w) {
+			kept = append(kept, w)
+		}
+	}
+	if len(kept) == 0 {
+		delete(waiting, hash)
+	} else {
+		waiting[hash] = kept
+	}
+	return len(kept) < len(pending)
+}
+
+// giveUp tells whoever is still waiting for content that it never came.
+func giveUp(hash string, w *waitingFile) {
+	blobsMutex.Lock()
+	waited := unwait(hash, func(x *waitingFile) bool { return x == w })
+	blobsMutex.Unlock()
+	if !waited {
+		return
+	}
+	if w.raw != nil {
+		reject(w.p, protocol.Rejected{Type: protocol.TypeFile, File: w.name, Reason: "its content never reached the server"})
+	} else {
+		reject(w.p, protocol.Rejected{Type: protocol.TypeWant, Hash: hash, Reason: "nobody sent the content"})
+	}
+}
+
+// putBlob must be called with blobsMutex held.
+func putBlob(hash string, content []byte) {
+	if e, ok := blobs[hash]; ok {
+		blobOrder.MoveToFront(e)
+		return
+	}
+	blobs[hash] = blobOrder.PushFront(&blob{hash: hash, content: content})
+	blobBytes += len(content)
+	for blobBytes > blobsLimit && blobOrder.Len() > 1 {
+		dropBlob(blobOrder.Back().Value.(*blob).hash)
+	}
+}
+
+// getBlob must be called with blobsMutex held.
+func getBlob(hash string) ([]byte, bool) {
+	e, ok := blobs[hash]
+	if !ok {
+		return nil, false
+	}
+	blobOrder.MoveToFront(e)
+	return e.Value.(*blob).content, true
+}
+
+// dropBlob must be called with blobsMutex held.
+func dropBlob(hash string) {
+	if e, ok := blobs[hash]; ok {
+		blobOrder.Remove(e)
+		blobBytes -= len(e.Value.(*blob).content)
+		delete(blobs, hash)
+	}
+}
+
+// hasBlob must be called with blobsMutex held.
+func hasBlob(p *wsutil.Peer, hash string) {
+	if has[p] == nil {
+		has[p] = make(map[string]bool)
+	}
+	has[p][hash] = true
+}
+
+// resolveFile fills in the content of a file message sent as a reference,
+// and keeps content sent in full. If the server doesn't have the content
+// it asks the sender for it and holds the message back.
+func resolveFile(p *wsutil.Peer, msg protocol.Message, raw []byte) (protocol.Message, bool) {
+	var file protocol.File
+	if msg.Unmarshal(&file) != nil {
+		return msg, false
+	}
+	name := filepath.Base(file.Name)
+	blobsMutex.Lock()
+	if file.Hash == "" {
+		file.Hash = history.Hash(file.Content)
+		putBlob(file.Hash, file.Content)
+		// a full copy supersedes p's changes still waiting for content
+		for hash := range waiting {
+			unwait(hash, func(w *waitingFile) bool { return w.p == p && w.raw != nil && w.name == name })
+		}
+	} else if content, ok := getBlob(file.Hash); ok {
+		file.Content = content
+	} else {
+		wait(file.Hash, &waitingFile{p: p, name: name, raw: raw})
+		blobsMutex.Unlock()
+		if out, err := protocol.Encode(protocol.TypeWant, protocol.Want{Hashes: []string{file.Hash}}); err == nil {
+			p.Write(websocket.TextMessage, out)
+		}
+		return msg, false
+	}
+	hasBlob(p, file.Hash)
+	blobsMutex.Unlock()
+
+	file.Hash = ""
+	data, err := json.Marshal(file)
+	if err != nil {
+		return msg, false
+	}
+	msg.Data = data
+	return msg, true
+}
+
+// sendFile relays a resolved file message to everyone except skip, or only
+// to its addressee, as a reference to those who already have its content.
+func sendFile(skip *wsutil.Peer, msg protocol.Message) {
+	var file protocol.File
+	if msg.Unmarshal(&file) != nil {
+		return
+	}
+	full, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	hash := history.Hash(file.Content)
+	data, _ := json.Marshal(protocol.File{Name: file.Name, Hash: hash, To: file.To})
+	ref, err := json.Marshal(protocol.Message{Type: msg.Type, From: msg.From, Data: data})
+	if err != nil {
+		return
+	}
+
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	blobsMutex.Lock()
+	defer blobsMutex.Unlock()
+	for client := range clients {
+		if client == skip || file.To != "" && client.Name != file.To {
+			continue
+		}
+		out := full
+		if len(file.Content) > 0 && has[client][hash] {
+			out = ref
+		}
+		hasBlob(client, hash)
+		if err := client.Write(websocket.TextMessage, out); err != nil {
+			fmt.Println("Error writing message to other clients: ", err)
+		}
+	}
+}
+
+// sendBlobs answers a participant's Want. Content the server no longer
+// has is asked for from someone else who has it.
+func sendBlobs(p *wsutil.Peer, msg protocol.Message) {
+	var want protocol.Want
+	if msg.Unmarshal(&want) != nil {
+		return
+	}
+	for _, hash := range want.Hashes {
+		blobsMutex.Lock()
+		content, ok := getBlob(hash)
+		if ok {
+			hasBlob(p, hash)
+		} else if q := holder(p, hash); q != nil {
+			wait(hash, &waitingFile{p: p})
+			blobsMutex.Unlock()
+			if out, err := protocol.Encode(protocol.TypeWant, protocol.Want{Hashes: []string{hash}}); err == nil {
+				q.Write(websocket.TextMessage, out)
+			}
+			continue
+		}
+		blobsMutex.Unlock()
+		if !ok {
+			log.Printf("Nobody has the content %s wants", p.Name)
+			reject(p, protocol.Rejected{Type: protocol.TypeWant, Hash: hash, Reason: "nobody has the content"})
+			continue
+		}
+		writeBlob(p, hash, content)
+	}
+}
+
+// holder finds someone other than p who has the content. It must be called
+// with blobsMutex held.
+func holder(p *wsutil.Peer, hash string) *wsutil.Peer {
+	for q, hashes := range has {
+		if q != p && hashes[hash] {
+			return q
+		}
+	}
+	return nil
+}
+
+func writeBlob(p *wsutil.Peer, hash string, content []byte) {
+	out, err := protocol.Encode(protocol.TypeBlob, protocol.Blob{Hash: hash, Content: content})
+	if err != nil {
+		return
+	}
+	if err := p.Write(websocket.TextMessage, out); err != nil {
+		fmt.Println("Error writing message to the client: ", err)
+	}
+}
+
+// receiveBlob keeps content a participant sent on request, relays the file
+// messages that were waiting for it and passes it on to those who asked.
+func receiveBlob(p *wsutil.Peer, msg protocol.Message) {
+	var b protocol.Blob
+	if msg.Unmarshal(&b) != nil || history.Hash(b.Content) != b.Hash {
+		return
+	}
+	blobsMutex.Lock()
+	putBlob(b.Hash, b.Content)
+	hasBlob(p, b.Hash)
+	pending := waiting[b.Hash]
+	delete(waiting, b.Hash)
+	for _, w := range pending {
+		if w.raw == nil {
+			hasBlob(w.p, b.Hash)
+		}
+	}
+	blobsMutex.Unlock()
+	for _, w := range pending {
+		if w.raw == nil {
+			writeBlob(w.p, b.Hash, b.Content)
+		} else {
+			relay(w.p, w.raw)
+		}
+	}
+}
+
+// forgetBlobs drops what the server knew about a participant that left,
+// and the contents nobody still connected has.
+func forgetBlobs(p *wsutil.Peer) {
+	blobsMutex.Lock()
+	defer blobsMutex.Unlock()
+	delete(has, p)
+	for hash := range waiting {
+		unwait(hash, func(w *waitingFile) bool { return w.p == p })
+	}
+	for hash := range blobs {
+		if holder(nil, hash) == nil {
+			dropBlob(hash)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/history"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+func TestWaitingExpires(t *testing.T) {
+	saved := blobWait
+	blobWait = 50 * time.Millisecond
+	t.Cleanup(func() { blobWait = saved })
+	srv := startServer(t)
+	alice := join(t, srv, "alice", "")
+	bob := join(t, srv, "bob", "")
+	missing := history.Hash([]byte("nobody has this"))
+
+	// alice refers to content the server doesn't have and never sends it
+	alice.send(protocol.TypeFile, protocol.File{Name: "a.py", Hash: missing})
+	alice.expect(protocol.TypeWant)
+	var r protocol.Rejected
+	if err := alice.expect(protocol.TypeRejected).Unmarshal(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != protocol.TypeFile || r.File != "a.py" {
+		t.Errorf("alice got %+v", r)
+	}
+
+	// bob asks for content nobody has
+	bob.send(protocol.TypeWant, protocol.Want{Hashes: []string{missing}})
+	if err := bob.expect(protocol.TypeRejected).Unmarshal(&r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Type != protocol.TypeWant || r.Hash != missing {
+		t.Errorf("bob got %+v", r)
+	}
+
+	// a full copy replaces the reference still waiting
+	alice.send(protocol.TypeFile, protocol.File{Name: "b.py", Hash: missing})
+	alice.expect(protocol.TypeWant)
+	alice.send(protocol.TypeFile, protocol.File{Name: "b.py", Content: []byte("full")})
+	var file protocol.File
+	if err := bob.expect(protocol.TypeFile).Unmarshal(&file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != "b.py" || string(file.Content) != "full" {
+		t.Errorf("bob got %+v", file)
+	}
+	time.Sleep(2 * blobWait)
+	bob.send(protocol.TypeChat, protocol.Chat{Text: "done"})
+	if msg := alice.next(); msg.Type == protocol.TypeRejected {
+		t.Errorf("alice's superseded change was rejected: %s", msg.Data)
+	}
+}
+
+func TestSendFileRefersToKnownContent(t *testing.T) {
+	srv := startServer(t)
+	alice := join(t, srv, "alice", "")
+	bob := join(t, srv, "bob", "")
+	content := []byte("print('hello')\n")
+
+	tests := []struct {
+		name string
+		full bool
+	}{
+		{"a.py", true},  // new to bob
+		{"b.py", false}, // a copy: bob has it now
+		{"a.py", false},
+	}
+	for _, tt := range tests {
+		alice.send(protocol.TypeFile, protocol.File{Name: tt.name, Content: content})
+		var file protocol.File
+		if err := bob.expect(protocol.TypeFile).Unmarshal(&file); err != nil {
+			t.Fatal(err)
+		}
+		if file.Name != tt.name {
+			t.Fatalf("bob got %s, want %s", file.Name, tt.name)
+		}
+		if tt.full && (string(file.Content) != string(content) || file.Hash != "") {
+			t.Errorf("%s: bob got %+v, want the content", tt.name, file)
+		}
+		if !tt.full && (len(file.Content) > 0 || file.Hash != history.Hash(content)) {
+			t.Errorf("%s: bob got %+v, want a reference", tt.name, file)
+		}
+	}
+}
+
+func TestEvictedContent(t *testing.T) {
+	limit := blobsLimit
+	blobsLimit = 10
+	t.Cleanup(func() { blobsLimit = limit })
+	srv := startServer(t)
+
+	// only alice has old, and it is evicted when she sends something bigger
+	alice := join(t, srv, "alice", "")
+	old := []byte("old\n")
+	alice.send(protocol.TypeFile, protocol.File{Name: "a.py", Content: old})
+	alice.send(protocol.TypeFile, protocol.File{Name: "a.py", Content: []byte("much newer content\n")})
+	carol := join(t, srv, "carol", "")
+	hash := history.Hash(old)
+
+	// carol asks for it, the server asks alice, who has it
+	carol.send(protocol.TypeWant, protocol.Want{Hashes: []string{hash}})
+	var want protocol.Want
+	if err := alice.expect(protocol.TypeWant).Unmarshal(&want); err != nil {
+		t.Fatal(err)
+	}
+	if len(want.Hashes) != 1 || want.Hashes[0] != hash {
+		t.Fatalf("alice was asked for %v", want.Hashes)
+	}
+
+	// alice reverts to old, referring to it, while the server still waits
+	alice.send(protocol.TypeFile, protocol.File{Name: "a.py", Hash: hash})
+	alice.expect(protocol.TypeWant)
+
+	// content that doesn't match its hash is dropped
+	alice.send(protocol.TypeBlob, protocol.Blob{Hash: hash, Content: []byte("forged")})
+	alice.send(protocol.TypeBlob, protocol.Blob{Hash: hash, Content: old})
+
+	var blob protocol.Blob
+	if err := carol.expect(protocol.TypeBlob).Unmarshal(&blob); err != nil {
+		t.Fatal(err)
+	}
+	if string(blob.Content) != string(old) {
+		t.Errorf("carol got %q", blob.Content)
+	}
+	var file protocol.File
+	if err := carol.expect(protocol.TypeFile).Unmarshal(&file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != "a.py" || file.Hash != hash {
+		t.Errorf("carol got %+v, want a reference to what she was just sent", file)
+	}
+}
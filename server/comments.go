@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/comments"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/go-johnnyhe/waveland/internal/wsutil"
+)
+
+// The server keeps the comments, and the latest content of each file to
+// move them along, so late joiners get them where they belong.
+var commentStore, _ = comments.Open("")
+var latest = make(map[string][]byte)
+var latestMutex = &sync.Mutex{}
+
+// stampComment sets the author and time of new comments. Only the author or
+// the host may change an existing one.
+func stampComment(p *wsutil.Peer, msg protocol.Message) (json.RawMessage, bool) {
+	var c protocol.Comment
+	if err := msg.Unmarshal(&c); err != nil || c.ID == "" || c.File == "" {
+		return nil, false
+	}
+	c.File = filepath.Base(c.File)
+	if existing, ok := commentStore.Get(c.ID); ok {
+		if existing.Author != p.Name && !p.Host {
+			reject(p, protocol.Rejected{Type: msg.Type, File: c.File, Reason: "only " + existing.Author + " can change that comment"})
+			return nil, false
+		}
+		c.Author, c.Time = existing.Author, existing.Time
+	} else {
+		c.Author, c.Time = p.Name, time.Now()
+	}
+	if c.EndLine < c.Line {
+		c.EndLine = c.Line
+	}
+	commentStore.Put(c)
+	data, err := json.Marshal(c)
+	return data, err == nil
+}
+
+// trackFile moves comments along with a relayed change.
+func trackFile(msg protocol.Message) {
+	var file protocol.File
+	if msg.Unmarshal(&file) != nil {
+		return
+	}
+	name := filepath.Base(file.Name)
+	latestMutex.Lock()
+	previous, seen := latest[name]
+	latest[name] = file.Content
+	latestMutex.Unlock()
+	if seen {
+		commentStore.Remap(name, previous, file.Content)
+	}
+}
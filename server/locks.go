@@ -0,0 +1,89 @@
+package server
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/go-johnnyhe/waveland/internal/wsutil"
+)
+
+// locks maps a file to the participant who claimed it.
+var locks = make(map[string]string)
+var locksMutex = &sync.Mutex{}
+
+// lockMessage handles lock and unlock requests. The owner or the host can
+// release a lock.
+func lockMessage(p *wsutil.Peer, msg protocol.Message) {
+	var req protocol.Lock
+	if msg.Unmarshal(&req) != nil || req.File == "" {
+		return
+	}
+	file := filepath.Base(req.File)
+
+	locksMutex.Lock()
+	owner := locks[file]
+	var reason string
+	switch {
+	case msg.Type == protocol.TypeLock && owner != "" && owner != p.Name:
+		reason = "locked by " + owner
+	case msg.Type == protocol.TypeLock:
+		locks[file] = p.Name
+		owner = p.Name
+	case owner == "":
+		reason = "not locked"
+	case owner != p.Name && !p.Host:
+		reason = "locked by " + owner
+	default:
+		delete(locks, file)
+		owner = ""
+	}
+	locksMutex.Unlock()
+
+	if reason != "" {
+		reject(p, protocol.Rejected{Type: msg.Type, File: file, Reason: reason})
+		return
+	}
+	log.Printf("%s: %s %s", p.Name, msg.Type, file)
+	broadcast(nil, protocol.TypeLock, protocol.Lock{File: file, Owner: owner})
+}
+
+// lockedBy returns who else holds a lock on file, or "".
+func lockedBy(p *wsutil.Peer, file string) string {
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+	if owner := locks[filepath.Base(file)]; owner != p.Name {
+		return owner
+	}
+	return ""
+}
+
+// releaseLocks frees everything p held, when it disconnects.
+func releaseLocks(p *wsutil.Peer) {
+	locksMutex.Lock()
+	var released []string
+	for file, owner := range locks {
+		if owner == p.Name {
+			delete(locks, file)
+			released = append(released, file)
+		}
+	}
+	locksMutex.Unlock()
+	for _, file := range released {
+		broadcast(nil, protocol.TypeLock, protocol.Lock{File: file})
+	}
+}
+
+// Locks lists the locked files.
+func Locks() []protocol.Lock {
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+	list := make([]protocol.Lock, 0, len(locks))
+	for file, owner := range locks {
+		list = append(list, protocol.Lock{File: file, Owner: owner})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].File < list[j].File })
+	return list
+}
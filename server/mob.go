@@ -0,0 +1,181 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/go-johnnyhe/waveland/internal/wsutil"
+)
+
+// In mob mode only the driver's changes are relayed; everyone else
+// navigates. The role goes around in the order people joined.
+var mobMutex = &sync.Mutex{}
+var mobOn bool
+var driver string
+var rotateEvery time.Duration
+var rotateTimer *time.Timer
+var driverUntil time.Time
+
+// StartMob turns on mob mode with the host driving first. With rotate > 0
+// the role passes on by itself.
+func StartMob(rotate time.Duration) {
+	mobMutex.Lock()
+	mobOn = true
+	rotateEvery = rotate
+	mobMutex.Unlock()
+	if first := firstDriver(); first != "" {
+		SetDriver(first)
+	}
+}
+
+func StopMob() {
+	mobMutex.Lock()
+	mobOn = false
+	driver = ""
+	if rotateTimer != nil {
+		rotateTimer.Stop()
+	}
+	mobMutex.Unlock()
+	broadcast(nil, protocol.TypeDriver, protocol.Driver{})
+}
+
+// SetDriver hands the driver role to name and restarts the rotation clock.
+func SetDriver(name string) error {
+	if !isParticipant(name) {
+		return fmt.Errorf("%s is not in the session", name)
+	}
+	mobMutex.Lock()
+	if !mobOn {
+		mobMutex.Unlock()
+		return fmt.Errorf("mob mode is off")
+	}
+	driver = name
+	if rotateTimer != nil {
+		rotateTimer.Stop()
+	}
+	driverUntil = time.Time{}
+	if rotateEvery > 0 {
+		driverUntil = time.Now().Add(rotateEvery)
+		rotateTimer = time.AfterFunc(rotateEvery, RotateDriver)
+	}
+	mobMutex.Unlock()
+
+	log.Printf("%s is driving", name)
+	broadcast(nil, protocol.TypeDriver, DriverState())
+	return nil
+}
+
+// RotateDriver passes the role to the next participant.
+func RotateDriver() {
+	mobMutex.Lock()
+	on, current := mobOn, driver
+	mobMutex.Unlock()
+	if on {
+		SetDriver(nextAfter(current))
+	}
+}
+
+// DriverState returns who drives, or nil outside mob mode.
+func DriverState() *protocol.Driver {
+	mobMutex.Lock()
+	defer mobMutex.Unlock()
+	if !mobOn {
+		return nil
+	}
+	state := &protocol.Driver{Name: driver, Rotate: rotateEvery, Until: driverUntil}
+	if next := nextAfter(driver); rotateEvery > 0 && next != driver {
+		state.Next = next
+	}
+	return state
+}
+
+// mayWrite reports whether p's file changes are relayed.
+func mayWrite(p *wsutil.Peer) bool {
+	mobMutex.Lock()
+	defer mobMutex.Unlock()
+	return !mobOn || p.Name == driver
+}
+
+// handoff lets the driver, or the host, pass the role on.
+func handoff(p *wsutil.Peer, msg protocol.Message) {
+	var h protocol.Handoff
+	if msg.Unmarshal(&h) != nil {
+		return
+	}
+	mobMutex.Lock()
+	allowed := mobOn && (p.Host || p.Name == driver)
+	current := driver
+	mobMutex.Unlock()
+	if !allowed {
+		reject(p, protocol.Rejected{Type: msg.Type, Reason: "only the driver can hand off"})
+		return
+	}
+	to := h.To
+	if to == "" {
+		to = nextAfter(current)
+	}
+	if err := SetDriver(to); err != nil {
+		reject(p, protocol.Rejected{Type: msg.Type, Reason: err.Error()})
+	}
+}
+
+// claimDriver gives the first participant to arrive the role when mob mode
+// was started before anyone joined.
+func claimDriver(p *wsutil.Peer) {
+	mobMutex.Lock()
+	vacant := mobOn && driver == ""
+	mobMutex.Unlock()
+	if vacant {
+		SetDriver(p.Name)
+	}
+}
+
+// driverLeft moves the role on when the driver disconnects.
+func driverLeft(p *wsutil.Peer) {
+	mobMutex.Lock()
+	left := mobOn && p.Name == driver
+	if left {
+		driver = ""
+	}
+	mobMutex.Unlock()
+	if !left {
+		return
+	}
+	if first := firstDriver(); first != "" {
+		SetDriver(first)
+	}
+}
+
+func firstDriver() string {
+	list := Participants()
+	if len(list) == 0 {
+		return ""
+	}
+	return list[0].Name
+}
+
+// nextAfter must not be called with clientsMutex held.
+func nextAfter(name string) string {
+	list := Participants()
+	for i, p := range list {
+		if p.Name == name {
+			return list[(i+1)%len(list)].Name
+		}
+	}
+	if len(list) > 0 {
+		return list[0].Name
+	}
+	return ""
+}
+
+func isParticipant(name string) bool {
+	for _, p := range Participants() {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/go-johnnyhe/waveland/internal/wsutil"
+	"github.com/gorilla/websocket"
+)
+
+// Terminal viewers are connections made with 'waveland join --terminal'.
+// They only receive the host's shared shell, never files or presence. Each
+// maps to the participant it watches for, the one whose terminal token it
+// presented, or nil. Only viewers tied to a participant can be given
+// control, so nobody can take over a name just by watching under it.
+var viewers = make(map[*wsutil.Peer]*wsutil.Peer)
+var terminalTokens = make(map[string]*wsutil.Peer)
+var terminalMutex = &sync.Mutex{}
+
+var terminalShared bool
+var terminalInput func(name string, data []byte)
+var terminalResize func(name string, rows, cols int)
+var terminalControl = make(map[*wsutil.Peer]bool)
+var scrollback []byte
+
+// scrollbackLimit is how much recent output late viewers get to catch up.
+const scrollbackLimit = 64 * 1024
+
+// ShareTerminal starts relaying a shell shared by the host. input and resize
+// are only ever called for participants the host gave control to.
+func ShareTerminal(input func(name string, data []byte), resize func(name string, rows, cols int)) {
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+	terminalShared = true
+	terminalInput = input
+	terminalResize = resize
+}
+
+func StopSharingTerminal() {
+	terminalMutex.Lock()
+	terminalShared = false
+	terminalInput = nil
+	terminalResize = nil
+	terminalMutex.Unlock()
+	BroadcastTerminal([]byte("\r\n[shared shell exited]\r\n"))
+}
+
+// BroadcastTerminal sends shell output to every viewer.
+func BroadcastTerminal(data []byte) {
+	out, err := protocol.Encode(protocol.TypeTermOutput, protocol.TermData{Data: data})
+	if err != nil {
+		return
+	}
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+	scrollback = append(scrollback, data...)
+	if len(scrollback) > scrollbackLimit {
+		scrollback = scrollback[len(scrollback)-scrollbackLimit:]
+	}
+	for v := range viewers {
+		if err := v.Write(websocket.TextMessage, out); err != nil {
+			log.Printf("Error writing to terminal viewer %s: %v", v.Name, err)
+		}
+	}
+}
+
+// Scrollback returns the most recent shell output.
+func Scrollback() []byte {
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+	return append([]byte(nil), scrollback...)
+}
+
+// SetTerminalControl lets the participant called name type into the shared
+// shell from their viewers, or stops them. Control goes to the connections
+// watching right now and ends when they or the participant disconnect.
+func SetTerminalControl(name string, allowed bool) error {
+	out, err := protocol.Encode(protocol.TypeTermControl, protocol.TermControl{Name: name, Allowed: allowed})
+	if err != nil {
+		return err
+	}
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+	found, claimed := false, false
+	for v, owner := range viewers {
+		if owner == nil || owner.Name != name {
+			claimed = claimed || v.Name == name
+			continue
+		}
+		if allowed {
+			terminalControl[v] = true
+		} else {
+			delete(terminalControl, v)
+		}
+		v.Write(websocket.TextMessage, out)
+		found = true
+	}
+	switch {
+	case found:
+		return nil
+	case claimed:
+		return fmt.Errorf("%s watches the shared shell without having joined the session, so can't be given control", name)
+	default:
+		return fmt.Errorf("%s isn't watching the shared shell", name)
+	}
+}
+
+// terminalToken gives a participant the token its viewers present to watch
+// under its name.
+func terminalToken(p *wsutil.Peer) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	terminalMutex.Lock()
+	terminalTokens[token] = p
+	terminalMutex.Unlock()
+	return token
+}
+
+// forgetTerminalToken ends what p's viewers may do when p leaves.
+func forgetTerminalToken(p *wsutil.Peer) {
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+	for token, owner := range terminalTokens {
+		if owner == p {
+			delete(terminalTokens, token)
+		}
+	}
+	for v, owner := range viewers {
+		if owner != p {
+			continue
+		}
+		viewers[v] = nil
+		if terminalControl[v] {
+			delete(terminalControl, v)
+			if out, err := protocol.Encode(protocol.TypeTermControl, protocol.TermControl{Name: v.Name}); err == nil {
+				v.Write(websocket.TextMessage, out)
+			}
+		}
+	}
+}
+
+// addViewer names a viewer after the participant whose token it presented.
+// Anyone else gets a name no participant or viewer has.
+func addViewer(p *wsutil.Peer, token string) error {
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+
+	owner := terminalTokens[token]
+	if owner != nil {
+		p.Name = owner.Name
+	} else {
+		p.Name = uniqueName(p.Name, func(n string) bool {
+			for v := range viewers {
+				if v.Name == n {
+					return true
+				}
+			}
+			return isParticipant(n)
+		})
+	}
+	welcome, err := protocol.Encode(protocol.TypeWelcome, protocol.Welcome{Name: p.Name, Terminal: terminalShared})
+	if err != nil {
+		return err
+	}
+	if err := p.Write(websocket.TextMessage, welcome); err != nil {
+		return err
+	}
+	if !terminalShared {
+		return nil
+	}
+	if len(scrollback) > 0 {
+		out, _ := protocol.Encode(protocol.TypeTermOutput, protocol.TermData{Data: scrollback})
+		p.Write(websocket.TextMessage, out)
+	}
+	viewers[p] = owner
+	return nil
+}
+
+func removeViewer(p *wsutil.Peer) {
+	terminalMutex.Lock()
+	defer terminalMutex.Unlock()
+	delete(viewers, p)
+	delete(terminalControl, p)
+}
+
+// terminalMessage handles keystrokes and resizes from viewers, dropping
+// them unless the sender has been given control.
+func terminalMessage(p *wsutil.Peer, msg protocol.Message) {
+	terminalMutex.Lock()
+	allowed := terminalShared && terminalControl[p]
+	input, resize := terminalInput, terminalResize
+	terminalMutex.Unlock()
+	if !allowed {
+		return
+	}
+
+	switch msg.Type {
+	case protocol.TypeTermInput:
+		var in protocol.TermData
+		if err := msg.Unmarshal(&in); err == nil {
+			input(p.Name, in.Data)
+		}
+	case protocol.TypeTermResize:
+		var size protocol.TermSize
+		if err := msg.Unmarshal(&size); err == nil && size.Rows > 0 && size.Cols > 0 {
+			resize(p.Name, size.Rows, size.Cols)
+		}
+	}
+}
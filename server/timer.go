@@ -0,0 +1,116 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/journal"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+)
+
+// Phases suggested for a mock interview; any other name works too.
+var Phases = []string{"clarify", "code", "test", "discuss"}
+
+var timerMutex = &sync.Mutex{}
+var timer *protocol.Timer
+var timerExpiry *time.Timer
+
+// SetTimer configures the session timer. It starts when the first peer joins,
+// or right away with StartTimer. With autoFreeze, files become read-only for
+// everyone but the host when time is up.
+func SetTimer(d time.Duration, autoFreeze bool) {
+	timerMutex.Lock()
+	timer = &protocol.Timer{Duration: d, AutoFreeze: autoFreeze}
+	timerMutex.Unlock()
+}
+
+// StartTimer (re)starts the clock, with a new duration if d > 0.
+func StartTimer(d time.Duration) {
+	timerMutex.Lock()
+	if timer == nil {
+		timer = &protocol.Timer{}
+	}
+	if d > 0 {
+		timer.Duration = d
+	}
+	timer.Started = time.Now()
+	if timerExpiry != nil {
+		timerExpiry.Stop()
+	}
+	timerExpiry = time.AfterFunc(timer.Duration, expire)
+	state := *timer
+	timerMutex.Unlock()
+
+	journal.Add(journal.KindTimer, "", state.Duration.String())
+	log.Printf("Timer started: %s", state.Duration)
+	broadcast(nil, protocol.TypeTimer, state)
+}
+
+// startTimerOnJoin starts a configured timer when the first peer arrives.
+func startTimerOnJoin() {
+	timerMutex.Lock()
+	waiting := timer != nil && timer.Started.IsZero() && timer.Duration > 0
+	timerMutex.Unlock()
+	if waiting {
+		StartTimer(0)
+	}
+}
+
+func SetPhase(phase string) {
+	timerMutex.Lock()
+	if timer == nil {
+		timer = &protocol.Timer{}
+	}
+	timer.Phase = phase
+	state := *timer
+	timerMutex.Unlock()
+
+	journal.Add(journal.KindPhase, "", phase)
+	broadcast(nil, protocol.TypeTimer, state)
+}
+
+// Freeze makes files read-only for everyone but the host, or lifts that.
+func Freeze(frozen bool) {
+	timerMutex.Lock()
+	if timer == nil {
+		timer = &protocol.Timer{}
+	}
+	timer.Frozen = frozen
+	state := *timer
+	timerMutex.Unlock()
+
+	if frozen {
+		journal.Add(journal.KindFreeze, "", "frozen")
+	} else {
+		journal.Add(journal.KindFreeze, "", "unfrozen")
+	}
+	broadcast(nil, protocol.TypeTimer, state)
+}
+
+func expire() {
+	timerMutex.Lock()
+	freeze := timer != nil && timer.AutoFreeze
+	timerMutex.Unlock()
+	log.Printf("Time is up")
+	if freeze {
+		Freeze(true)
+	}
+}
+
+// TimerState returns the current timer, or nil if there is none.
+func TimerState() *protocol.Timer {
+	timerMutex.Lock()
+	defer timerMutex.Unlock()
+	if timer == nil {
+		return nil
+	}
+	state := *timer
+	return &state
+}
+
+func frozen() bool {
+	timerMutex.Lock()
+	defer timerMutex.Unlock()
+	return timer != nil && timer.Frozen
+}
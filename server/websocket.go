@@ -1,11 +1,19 @@
 package server
 
 import (
-	"net/http"
-	"sync"
+	"encoding/json"
 	"fmt"
 	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/config"
+	"github.com/go-johnnyhe/waveland/internal/journal"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
 	"github.com/go-johnnyhe/waveland/internal/wsutil"
 	"github.com/gorilla/websocket"
 )
@@ -21,6 +29,24 @@ var upgrader = websocket.Upgrader {
 	},
 }
 
+// chatHistory keeps the latest chat messages for participants who join late.
+var chatHistory []protocol.Chat
+var chatMutex = &sync.Mutex{}
+
+const chatHistoryLimit = 200
+
+// HostToken is a secret shared between the server and the host's own client
+// so the server can tell which participant is the host.
+var HostToken string
+
+// Review turns changes from anyone but the host into proposals for the host.
+var Review bool
+
+// Interview makes the host the interviewer and everyone else a candidate.
+// Files matching Private never leave the host.
+var Interview bool
+var Private []string
+
 func StartServer(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -28,38 +54,46 @@ func StartServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	p := wsutil.NewPeer(conn)
 	conn.SetReadDeadline(time.Now().Add(60*time.Second))
-	conn.SetPongHandler(func(string) error {
+	conn.SetPongHandler(func(appData string) error {
 		conn.SetReadDeadline(time.Now().Add(60*time.Second))
+		if sent, err := strconv.ParseInt(appData, 10, 64); err == nil {
+			p.SetRTT(time.Since(time.Unix(0, sent)))
+		}
 		return nil
 	})
 
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
-	
-	p := wsutil.NewPeer(conn)
+
+	// pings carry their send time so the pong tells us the round trip
+	ping := func() error {
+		return p.Write(websocket.PingMessage, []byte(strconv.FormatInt(time.Now().UnixNano(), 10)))
+	}
 	go func() {
+		if err := ping(); err != nil {
+			return
+		}
 		for range ticker.C {
-			if err := p.Write(websocket.PingMessage, nil); err != nil {
+			if err := ping(); err != nil {
 				return
 			}
 		}
 	}()
 
-	log.Println("Connected to websocket!")
-
-	clientsMutex.Lock()
-	clients[p] = true
-	clientsMutex.Unlock()
-
-	defer func() {
-		conn.Close()
-		clientsMutex.Lock()
-		delete(clients, p)
-		clientsMutex.Unlock()
-		log.Printf("Client disconnected. Total clients now: %d", len(clients))
-	}()
-
+	defer conn.Close()
+	if err := greet(p); err != nil {
+		fmt.Println("Error joining the session: ", err)
+		return
+	}
+	if p.Terminal {
+		log.Printf("%s is watching the shared terminal", p.Name)
+		defer removeViewer(p)
+	} else {
+		log.Printf("%s connected", p.Name)
+		defer leave(p)
+	}
 
 	for {
 		msgType, msg, err := conn.ReadMessage()
@@ -68,18 +102,441 @@ func StartServer(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		if msgType == websocket.TextMessage {
-			log.Printf("Message received: %d bytes for file", len(msg))
-
-			clientsMutex.Lock()
-			for client := range clients {
-				if client != p {
-					err := client.Write(msgType, msg)
-					if err != nil {
-						fmt.Println("Error writing message to other clients: ", err)
-					}
-				}
+			relay(p, msg)
+		}
+	}
+}
+
+func leave(p *wsutil.Peer) {
+	clientsMutex.Lock()
+	delete(clients, p)
+	remaining := len(clients)
+	clientsMutex.Unlock()
+	broadcast(nil, protocol.TypeLeave, participant(p))
+	journal.Add(journal.KindLeave, p.Name, "")
+	driverLeft(p)
+	releaseLocks(p)
+	forgetTerminalToken(p)
+	forgetBlobs(p)
+	log.Printf("%s disconnected. Total clients now: %d", p.Name, remaining)
+}
+
+// greet waits for the peer's hello, registers it under a unique name and
+// tells everyone else it joined.
+func greet(p *wsutil.Peer) error {
+	_, raw, err := p.ReadMessage()
+	if err != nil {
+		return err
+	}
+	msg, err := protocol.Decode(raw)
+	if err != nil || msg.Type != protocol.TypeHello {
+		return fmt.Errorf("expected hello, is the other side running an older waveland?")
+	}
+	var hello protocol.Hello
+	if err := msg.Unmarshal(&hello); err != nil {
+		return err
+	}
+	if hello.Terminal {
+		p.Name = hello.Name
+		p.Terminal = true
+		p.Joined = time.Now()
+		return addViewer(p, hello.Token)
+	}
+
+	clientsMutex.Lock()
+	p.Name = uniqueName(hello.Name, func(n string) bool {
+		for client := range clients {
+			if client.Name == n {
+				return true
+			}
+		}
+		return false
+	})
+	p.Host = HostToken != "" && hello.Token == HostToken
+	p.Joined = time.Now()
+	clients[p] = true
+	clientsMutex.Unlock()
+
+	chatMutex.Lock()
+	history := append([]protocol.Chat(nil), chatHistory...)
+	chatMutex.Unlock()
+
+	welcome, err := protocol.Encode(protocol.TypeWelcome, protocol.Welcome{
+		Name:          p.Name,
+		Participants:  Participants(),
+		Chat:          history,
+		Role:          role(p),
+		Private:       Private,
+		Timer:         TimerState(),
+		Driver:        DriverState(),
+		Locks:         Locks(),
+		Review:        Review,
+		Comments:      commentStore.List(""),
+		TerminalToken: terminalToken(p),
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.Write(websocket.TextMessage, welcome); err != nil {
+		return err
+	}
+	broadcast(p, protocol.TypeJoin, participant(p))
+	journal.Add(journal.KindJoin, p.Name, role(p))
+	if !p.Host {
+		startTimerOnJoin()
+	}
+	claimDriver(p)
+	return nil
+}
+
+// uniqueName numbers name until taken says it is free.
+func uniqueName(name string, taken func(string) bool) string {
+	if name == "" {
+		name = "anonymous"
+	}
+	unique := name
+	for i := 2; taken(unique); i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	return unique
+}
+
+func relay(p *wsutil.Peer, raw []byte) {
+	msg, err := protocol.Decode(raw)
+	if err != nil {
+		log.Printf("Dropping malformed message from %s: %v", p.Name, err)
+		return
+	}
+	switch msg.Type {
+	case protocol.TypeHello:
+		return
+	case protocol.TypeTermInput, protocol.TypeTermResize:
+		terminalMessage(p, msg)
+		return
+	}
+	if p.Terminal {
+		return
+	}
+	// messages the server handles itself, and those only the host may send
+	switch msg.Type {
+	case protocol.TypeHandoff:
+		handoff(p, msg)
+		return
+	case protocol.TypeLock, protocol.TypeUnlock:
+		lockMessage(p, msg)
+		return
+	case protocol.TypeManifest:
+		manifest(p, msg)
+		return
+	case protocol.TypeWant:
+		sendBlobs(p, msg)
+		return
+	case protocol.TypeBlob:
+		receiveBlob(p, msg)
+		return
+	// only the host runs commands, and only the host reports on them
+	case protocol.TypeRunRequest:
+		msg.From = p.Name
+		if out, err := json.Marshal(msg); err == nil {
+			sendToHost(out)
+		}
+		return
+	case protocol.TypeRunStart, protocol.TypeRunOutput, protocol.TypeRunExit, protocol.TypeCheckResult, protocol.TypeHint:
+		if !p.Host {
+			return
+		}
+		if msg.Type == protocol.TypeCheckResult {
+			journalCheck(msg)
+		}
+	// what participants share with each other
+	case protocol.TypeFile, protocol.TypeCursor, protocol.TypeChat, protocol.TypeComment, protocol.TypeReview:
+	default:
+		// welcome, join, leave and the like only ever come from the server
+		log.Printf("Dropping %s from %s", msg.Type, p.Name)
+		return
+	}
+	if msg.Type == protocol.TypeFile {
+		var ok bool
+		if msg, ok = resolveFile(p, msg, raw); !ok {
+			return
+		}
+		// the host answering a joiner's reconciliation, see protocol.Manifest
+		var file protocol.File
+		if msg.Unmarshal(&file) == nil && file.To != "" {
+			if p.Host && !private(msg) {
+				msg.From = p.Name
+				sendFile(p, msg)
+			}
+			return
+		}
+	}
+	if msg.Type == protocol.TypeFile && !mayWrite(p) {
+		reject(p, protocol.Rejected{Type: msg.Type, File: fileName(msg), Reason: "only the driver can change files"})
+		return
+	}
+	if msg.Type == protocol.TypeFile {
+		if owner := lockedBy(p, fileName(msg)); owner != "" {
+			reject(p, protocol.Rejected{Type: msg.Type, File: fileName(msg), Reason: "locked by " + owner})
+			return
+		}
+	}
+	if msg.Type == protocol.TypeFile && !p.Host && frozen() {
+		log.Printf("Dropping a change from %s, time is up", p.Name)
+		return
+	}
+	if private(msg) {
+		log.Printf("Dropping %s about a private file from %s", msg.Type, p.Name)
+		return
+	}
+	if msg.Type == protocol.TypeFile && !p.Host && Review {
+		propose(p, msg)
+		return
+	}
+	if msg.Type == protocol.TypeReview {
+		if p.Host {
+			msg.From = p.Name
+			sendReview(msg)
+		}
+		return
+	}
+	// cursor moves and command output are frequent, not worth a log line each
+	if msg.Type != protocol.TypeCursor && msg.Type != protocol.TypeRunOutput {
+		log.Printf("Message received: %d bytes of %s from %s", len(raw), msg.Type, p.Name)
+	}
+
+	msg.From = p.Name
+	switch msg.Type {
+	case protocol.TypeChat:
+		data, ok := stampChat(p, msg)
+		if !ok {
+			return
+		}
+		msg.Data = data
+	case protocol.TypeComment:
+		data, ok := stampComment(p, msg)
+		if !ok {
+			return
+		}
+		msg.Data = data
+	case protocol.TypeFile:
+		trackFile(msg)
+		sendFile(p, msg)
+		return
+	}
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	send(p, out)
+}
+
+// stampChat fills in who sent a chat message and when, and remembers it.
+func stampChat(p *wsutil.Peer, msg protocol.Message) (json.RawMessage, bool) {
+	var chat protocol.Chat
+	if err := msg.Unmarshal(&chat); err != nil || chat.Text == "" {
+		return nil, false
+	}
+	chat.Name = p.Name
+	chat.Time = time.Now()
+	journal.Add(journal.KindChat, chat.Name, chat.Text)
+
+	chatMutex.Lock()
+	chatHistory = append(chatHistory, chat)
+	if len(chatHistory) > chatHistoryLimit {
+		chatHistory = chatHistory[len(chatHistory)-chatHistoryLimit:]
+	}
+	chatMutex.Unlock()
+
+	data, err := json.Marshal(chat)
+	return data, err == nil
+}
+
+// broadcast sends a server-originated message to everyone except skip.
+func broadcast(skip *wsutil.Peer, msgType string, payload any) {
+	out, err := protocol.Encode(msgType, payload)
+	if err != nil {
+		log.Printf("Error encoding %s: %v", msgType, err)
+		return
+	}
+	send(skip, out)
+}
+
+func send(skip *wsutil.Peer, out []byte) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	for client := range clients {
+		if client != skip {
+			if err := client.Write(websocket.TextMessage, out); err != nil {
+				fmt.Println("Error writing message to other clients: ", err)
 			}
-			clientsMutex.Unlock()
 		}
 	}
-}
\ No newline at end of file
+}
+
+// propose hands a change to the host for review instead of relaying it.
+func propose(p *wsutil.Peer, msg protocol.Message) {
+	log.Printf("%s proposes a change to %s", p.Name, fileName(msg))
+	msg.Type = protocol.TypeProposal
+	msg.From = p.Name
+	if out, err := json.Marshal(msg); err == nil {
+		sendToHost(out)
+	}
+}
+
+// sendReview delivers the host's answer to the proposer only.
+func sendReview(msg protocol.Message) {
+	var review protocol.Review
+	if msg.Unmarshal(&review) != nil {
+		return
+	}
+	if out, err := json.Marshal(msg); err == nil {
+		sendTo(review.To, out)
+	}
+}
+
+// manifest passes a joiner's manifest to the host and the host's answer
+// back to the joiner. Private files are left out of the answer.
+func manifest(p *wsutil.Peer, msg protocol.Message) {
+	msg.From = p.Name
+	if !p.Host {
+		if out, err := json.Marshal(msg); err == nil {
+			sendToHost(out)
+		}
+		return
+	}
+	var m protocol.Manifest
+	if msg.Unmarshal(&m) != nil {
+		return
+	}
+	files := m.Files[:0]
+	for _, f := range m.Files {
+		if !config.Match(Private, filepath.Base(f.Name)) {
+			files = append(files, f)
+		}
+	}
+	m.Files = files
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	msg.Data = data
+	if out, err := json.Marshal(msg); err == nil {
+		sendTo(m.To, out)
+	}
+}
+
+// sendTo writes out to the participant called name.
+func sendTo(name string, out []byte) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	for client := range clients {
+		if client.Name == name {
+			if err := client.Write(websocket.TextMessage, out); err != nil {
+				fmt.Println("Error writing message to the client: ", err)
+			}
+		}
+	}
+}
+
+// reject tells p that one of its messages was dropped.
+func reject(p *wsutil.Peer, rejected protocol.Rejected) {
+	out, err := protocol.Encode(protocol.TypeRejected, rejected)
+	if err != nil {
+		return
+	}
+	if err := p.Write(websocket.TextMessage, out); err != nil {
+		fmt.Println("Error writing message to the client: ", err)
+	}
+}
+
+func fileName(msg protocol.Message) string {
+	var file protocol.File
+	msg.Unmarshal(&file)
+	return filepath.Base(file.Name)
+}
+
+func sendToHost(out []byte) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	for client := range clients {
+		if client.Host {
+			if err := client.Write(websocket.TextMessage, out); err != nil {
+				fmt.Println("Error writing message to the host: ", err)
+			}
+		}
+	}
+}
+
+// private reports whether msg is about a file that must stay with the host.
+// Nobody gets those, not even from a candidate, so the host's copy can't be
+// overwritten either.
+func private(msg protocol.Message) bool {
+	if len(Private) == 0 {
+		return false
+	}
+	var name string
+	switch msg.Type {
+	case protocol.TypeFile:
+		var file protocol.File
+		if msg.Unmarshal(&file) != nil {
+			return true
+		}
+		name = file.Name
+	case protocol.TypeCursor:
+		var cursor protocol.Cursor
+		if msg.Unmarshal(&cursor) != nil {
+			return true
+		}
+		name = cursor.File
+	case protocol.TypeComment:
+		var comment protocol.Comment
+		if msg.Unmarshal(&comment) != nil {
+			return true
+		}
+		name = comment.File
+	default:
+		return false
+	}
+	return config.Match(Private, filepath.Base(name))
+}
+
+func journalCheck(msg protocol.Message) {
+	var result protocol.CheckResult
+	if msg.Unmarshal(&result) != nil {
+		return
+	}
+	outcome := "failed"
+	if result.Passed {
+		outcome = "passed"
+	}
+	journal.Add(journal.KindCheck, result.Command, outcome)
+}
+
+func role(p *wsutil.Peer) string {
+	switch {
+	case !Interview:
+		return ""
+	case p.Host:
+		return protocol.RoleInterviewer
+	default:
+		return protocol.RoleCandidate
+	}
+}
+
+func participant(p *wsutil.Peer) protocol.Participant {
+	return protocol.Participant{Name: p.Name, Host: p.Host, Role: role(p), Joined: p.Joined, Latency: p.RTT()}
+}
+
+// Participants lists everyone in the session, in the order they joined.
+func Participants() []protocol.Participant {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+	list := make([]protocol.Participant, 0, len(clients))
+	for client := range clients {
+		list = append(list, participant(client))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Joined.Before(list[j].Joined)
+	})
+	return list
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-johnnyhe/waveland/internal/journal"
+	"github.com/go-johnnyhe/waveland/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// TestMain keeps the session journal out of the source tree.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "waveland-server-")
+	if err != nil {
+		panic(err)
+	}
+	journal.Path = filepath.Join(dir, "journal.jsonl")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testPeer is a participant connected to a test server.
+type testPeer struct {
+	t       *testing.T
+	conn    *websocket.Conn
+	name    string
+	welcome protocol.Welcome
+}
+
+func startServer(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(StartServer))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// join connects to srv and waits for the welcome. The peer leaves when the
+// test ends.
+func join(t *testing.T, srv *httptest.Server, name, token string) *testPeer {
+	return connect(t, srv, protocol.Hello{Name: name, Token: token})
+}
+
+// watch connects a terminal viewer.
+func watch(t *testing.T, srv *httptest.Server, name, token string) *testPeer {
+	return connect(t, srv, protocol.Hello{Name: name, Token: token, Terminal: true})
+}
+
+func connect(t *testing.T, srv *httptest.Server, hello protocol.Hello) *testPeer {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testPeer{t: t, conn: conn}
+	p.send(protocol.TypeHello, hello)
+	if err := p.expect(protocol.TypeWelcome).Unmarshal(&p.welcome); err != nil {
+		t.Fatal(err)
+	}
+	p.name = p.welcome.Name
+	t.Cleanup(func() {
+		conn.Close()
+		for i := 0; i < 100 && isParticipant(p.name); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+	return p
+}
+
+func (p *testPeer) send(msgType string, payload any) {
+	p.t.Helper()
+	out, err := protocol.Encode(msgType, payload)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	if err := p.conn.WriteMessage(websocket.TextMessage, out); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+// next reads the next message, failing the test if none comes.
+func (p *testPeer) next() protocol.Message {
+	p.t.Helper()
+	p.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, raw, err := p.conn.ReadMessage()
+	if err != nil {
+		p.t.Fatalf("%s: %v", p.name, err)
+	}
+	msg, err := protocol.Decode(raw)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	return msg
+}
+
+// expect skips messages up to the next one of msgType.
+func (p *testPeer) expect(msgType string) protocol.Message {
+	p.t.Helper()
+	for {
+		if msg := p.next(); msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+func TestRelayDropsServerMessages(t *testing.T) {
+	srv := startServer(t)
+	mallory := join(t, srv, "mallory", "")
+	alice := join(t, srv, "alice", "")
+
+	forged := []struct {
+		msgType string
+		payload any
+	}{
+		{protocol.TypeWelcome, protocol.Welcome{Name: "mallory", Private: []string{"*"}}},
+		{protocol.TypeJoin, protocol.Participant{Name: "bob"}},
+		{protocol.TypeLeave, protocol.Participant{Name: "alice"}},
+		{protocol.TypeRejected, protocol.Rejected{Type: protocol.TypeFile, Reason: "no"}},
+		{protocol.TypeTermControl, protocol.TermControl{Name: "mallory", Allowed: true}},
+		{protocol.TypeTimer, protocol.Timer{Duration: time.Minute, Started: time.Now(), Frozen: true}},
+		{protocol.TypeDriver, protocol.Driver{Name: "x"}},
+		{protocol.TypeHandoff, protocol.Handoff{To: "mallory"}},
+		{protocol.TypeProposal, protocol.File{Name: "main.go", Content: []byte("package main")}},
+	}
+	for _, f := range forged {
+		mallory.send(f.msgType, f.payload)
+	}
+	// messages are relayed in order, so the chat comes after anything relayed
+	mallory.send(protocol.TypeChat, protocol.Chat{Text: "hi"})
+	for {
+		msg := alice.next()
+		if msg.Type == protocol.TypeChat {
+			break
+		}
+		if msg.From != "" {
+			t.Errorf("relayed %s from %s", msg.Type, msg.From)
+		}
+	}
+}
+
+func TestTerminalControl(t *testing.T) {
+	typed := make(chan string, 10)
+	ShareTerminal(func(name string, data []byte) {
+		typed <- name + ": " + string(data)
+	}, func(string, int, int) {})
+	t.Cleanup(StopSharingTerminal)
+	srv := startServer(t)
+
+	// someone watches as alice before alice has joined
+	impostor := watch(t, srv, "alice", "")
+	alice := join(t, srv, "alice", "")
+	viewer := watch(t, srv, "alice", alice.welcome.TerminalToken)
+	if viewer.name != alice.name {
+		t.Fatalf("alice's viewer is called %q", viewer.name)
+	}
+	if err := SetTerminalControl(alice.name, true); err != nil {
+		t.Fatal(err)
+	}
+	viewer.expect(protocol.TypeTermControl)
+
+	viewer.send(protocol.TypeTermInput, protocol.TermData{Data: []byte("ls")})
+	if got := <-typed; got != "alice: ls" {
+		t.Errorf("shell got %q", got)
+	}
+	impostor.send(protocol.TypeTermInput, protocol.TermData{Data: []byte("rm -rf ~")})
+	select {
+	case got := <-typed:
+		t.Errorf("shell got %q from a viewer without control", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"bob", "", "without having joined"},
+		{"dave", "not-a-token", "without having joined"},
+	}
+	for _, tt := range tests {
+		v := watch(t, srv, tt.name, tt.token)
+		if err := SetTerminalControl(v.name, true); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("SetTerminalControl(%s) = %v, want %q", v.name, err, tt.want)
+		}
+	}
+	if err := SetTerminalControl("carol", true); err == nil {
+		t.Error("gave control to someone who isn't watching")
+	}
+}
+
+func TestReviewKeepsPrivateFiles(t *testing.T) {
+	HostToken, Review, Private = "secret", true, []string{"notes*.md"}
+	t.Cleanup(func() { HostToken, Review, Private = "", false, nil })
+	srv := startServer(t)
+	host := join(t, srv, "host", "secret")
+	peer := join(t, srv, "peer", "")
+
+	peer.send(protocol.TypeFile, protocol.File{Name: "notes.md", Content: []byte("mine now")})
+	peer.send(protocol.TypeFile, protocol.File{Name: "main.go", Content: []byte("package main")})
+	var file protocol.File
+	if err := host.expect(protocol.TypeProposal).Unmarshal(&file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Name != "main.go" {
+		t.Errorf("host got a proposal for %s", file.Name)
+	}
+}